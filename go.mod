@@ -4,6 +4,26 @@ go 1.19
 
 require (
 	github.com/go-msvc/errors v1.2.0
-	github.com/go-msvc/utils v0.0.0-20221018100505-45c6828a2cde
-	github.com/stewelarend/logger v0.0.4
+	github.com/go-msvc/logger v0.0.0-20210121062433-1f3922644bec
+	github.com/go-playground/validator/v10 v10.15.5
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
+	golang.org/x/net v0.17.0
+	golang.org/x/sync v0.4.0
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/gabriel-vasile/mimetype v1.4.2 // indirect
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/go-playground/locales v0.14.1 // indirect
+	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/leodido/go-urn v1.2.4 // indirect
+	go.opentelemetry.io/otel/metric v1.16.0 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// accessLogger writes one JSON object per line for every request
+type accessLogger struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	errLog leveledLogger
+}
+
+type accessLogEntry struct {
+	Time        string  `json:"time"`
+	Method      string  `json:"method"`
+	Path        string  `json:"path"`
+	Operation   string  `json:"operation,omitempty"`
+	Status      int     `json:"status"`
+	DurationMs  float64 `json:"durationMs"`
+	Bytes       int64   `json:"bytes"`
+	RequestID   string  `json:"requestId"`
+	CausationID string  `json:"causationId,omitempty"`
+	DryRun      bool    `json:"dryRun,omitempty"`
+
+	//only set with Config.LogBodies, after redaction
+	RequestBody  json.RawMessage `json:"requestBody,omitempty"`
+	ResponseBody json.RawMessage `json:"responseBody,omitempty"`
+}
+
+func (l *accessLogger) log(entry accessLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		l.errLog.Errorf("failed to encode access log: %+v", err)
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		l.errLog.Errorf("failed to write access log: %+v", err)
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var sink bytes.Buffer
+	h := testHandler(t, Config{AccessLog: true, AccessLogWriter: &sink}, testMS{"get": testOper{handle: result("ok")}})
+	do(h, http.MethodGet, "/get?x=1", "", RequestIDHeader, "req-1")
+	do(h, http.MethodGet, "/missing", "")
+
+	lines := bytes.Split(bytes.TrimSpace(sink.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", sink.String())
+	}
+	var entry accessLogEntry
+	if err := json.Unmarshal(lines[0], &entry); err != nil {
+		t.Fatalf("invalid line %s: %+v", lines[0], err)
+	}
+	if entry.Method != http.MethodGet || entry.Path != "/get" || entry.Operation != "get" || entry.Status != http.StatusOK ||
+		entry.Bytes != int64(len(`"ok"`)) || entry.RequestID != "req-1" || entry.DurationMs < 0 || entry.Time == "" {
+		t.Fatalf("got %+v", entry)
+	}
+	if err := json.Unmarshal(lines[1], &entry); err != nil || entry.Status != http.StatusNotFound {
+		t.Fatalf("got %+v: %v", entry, err)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-msvc/errors"
+)
+
+// adminHandler serves the management endpoints on Config.AdminPort
+type adminHandler struct {
+	s         *server
+	endpoints map[string]http.Handler //by path
+	pprof     http.Handler            //nil unless pprof is enabled
+}
+
+func (h adminHandler) ServeHTTP(httpRes http.ResponseWriter, httpReq *http.Request) {
+	if handler, ok := h.endpoints[httpReq.URL.Path]; ok {
+		handler.ServeHTTP(httpRes, httpReq)
+		return
+	}
+	if h.pprof != nil && isPprofPath(httpReq.URL.Path) {
+		h.pprof.ServeHTTP(httpRes, httpReq)
+		return
+	}
+	h.s.config.ErrorWriter(httpRes, httpReq, http.StatusNotFound, errors.Errorc(http.StatusNotFound, "unknown management endpoint"))
+}
+
+// AdminAddr returns the address of the admin listener once serving, or "" without Config.AdminPort
+func (s *server) AdminAddr() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.adminAddr
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// freePort returns a TCP port that was free when checked
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestAdminPort(t *testing.T) {
+	s, url := startServer(t, Config{AdminPort: freePort(t), EnablePprof: true}, testMS{"get": testOper{handle: result("ok")}})
+	adminURL := "http://" + s.AdminAddr()
+	if _, port, _ := net.SplitHostPort(s.AdminAddr()); port != strconv.Itoa(s.config.AdminPort) {
+		t.Fatalf("admin listens on %s", s.AdminAddr())
+	}
+	for target, want := range map[string]int{
+		adminURL + "/healthz":      http.StatusOK,
+		adminURL + "/readyz":       http.StatusOK,
+		adminURL + "/debug/pprof/": http.StatusOK,
+		adminURL + "/get":          http.StatusNotFound,
+		url + "/get":               http.StatusOK,
+		url + "/healthz":           http.StatusNotFound,
+		url + "/debug/pprof/":      http.StatusNotFound,
+	} {
+		httpRes, err := http.Get(target)
+		if err != nil {
+			t.Fatalf("%s failed: %+v", target, err)
+		}
+		httpRes.Body.Close()
+		if httpRes.StatusCode != want {
+			t.Errorf("%s got %d, expected %d", target, httpRes.StatusCode, want)
+		}
+	}
+}
+
+func TestAdminShutdown(t *testing.T) {
+	s, _ := startServer(t, Config{AdminPort: freePort(t)}, testMS{})
+	adminURL := "http://" + s.AdminAddr()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %+v", err)
+	}
+	if httpRes, err := http.Get(adminURL + "/healthz"); err == nil {
+		httpRes.Body.Close()
+		t.Fatalf("admin port still serving after Shutdown: %d", httpRes.StatusCode)
+	}
+}
+
+func TestValidateAdminPort(t *testing.T) {
+	for _, c := range []Config{
+		{Addr: "localhost", Port: 8080, AdminPort: 8080},
+		{Addr: "localhost", Port: 8080, AdminPort: -1},
+		{Addr: "localhost", Port: 8080, AdminPort: 70000},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("adminPort:%d with port:%d accepted", c.AdminPort, c.Port)
+		}
+	}
+}
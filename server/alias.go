@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-msvc/errors"
+)
+
+const (
+	// DeprecationHeader is set to "true" on responses to requests that used an alias
+	// or a Deprecatable operation
+	DeprecationHeader = "Deprecation"
+	// SunsetHeader is the date after which a Deprecatable operation may be removed
+	SunsetHeader = "Sunset"
+)
+
+// deprecated sets the deprecation headers of an operation implementing Deprecatable
+// and returns false for other operations
+func deprecated(header http.Header, oper interface{}) bool {
+	deprecatable, ok := oper.(Deprecatable)
+	if !ok {
+		return false
+	}
+	since, sunset := deprecatable.DeprecatedSince(), deprecatable.SunsetAt()
+	if since.IsZero() && sunset.IsZero() {
+		return false
+	}
+	header.Set(DeprecationHeader, "true")
+	if !sunset.IsZero() {
+		header.Set(SunsetHeader, sunset.UTC().Format(http.TimeFormat))
+	}
+	return true
+}
+
+// checkAliases rejects aliases that lead back to themselves
+func checkAliases(aliases map[string]string) error {
+	for name := range aliases {
+		if _, err := resolveAlias(aliases, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveAlias follows aliases from name to the current operation name
+// an alias may point to another alias, e.g. after renaming an operation twice
+func resolveAlias(aliases map[string]string, name string) (string, error) {
+	seen := map[string]bool{name: true}
+	for {
+		next, ok := aliases[name]
+		if !ok {
+			return name, nil
+		}
+		if seen[next] {
+			return "", errors.Errorf("alias %s loops back to %s", name, next)
+		}
+		seen[next] = true
+		name = next
+	}
+}
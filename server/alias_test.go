@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAliases(t *testing.T) {
+	h, rec := loggedHandler(t, Config{Aliases: map[string]string{
+		"getUsr":    "fetchUser", //renamed twice
+		"fetchUser": "getUser",
+		"old":       "current", //shadowed by an operation
+		"gone":      "removed",
+	}}, testMS{
+		"getUser": testOper{handle: result("user")},
+		"old":     testOper{handle: result("old")},
+		"current": testOper{handle: result("current")},
+	})
+	for _, path := range []string{"/getUsr", "/fetchUser"} {
+		w := do(h, http.MethodGet, path, "")
+		if w.Code != http.StatusOK || w.Body.String() != `"user"` || w.Header().Get(DeprecationHeader) != "true" {
+			t.Fatalf("%s got %d %s %v", path, w.Code, w.Body, w.Header())
+		}
+	}
+	if !rec.contains("deprecated alias getUsr used for getUser") {
+		t.Fatalf("alias not logged: %q", rec.lines)
+	}
+	if w := do(h, http.MethodGet, "/getUser", ""); w.Header().Get(DeprecationHeader) != "" {
+		t.Fatalf("current name deprecated: %v", w.Header())
+	}
+	if w := do(h, http.MethodGet, "/old", ""); w.Body.String() != `"old"` || w.Header().Get(DeprecationHeader) != "" {
+		t.Fatalf("operation shadowed by an alias got %s %v", w.Body, w.Header())
+	}
+	if w := do(h, http.MethodGet, "/gone", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("alias to a missing operation got %d", w.Code)
+	}
+}
+
+func TestAliasLoops(t *testing.T) {
+	for _, aliases := range []map[string]string{
+		{"a": "a"},
+		{"a": "b", "b": "a"},
+		{"a": "b", "b": "c", "c": "b"},
+	} {
+		if err := (Config{Addr: "localhost", Aliases: aliases}).Validate(); err == nil {
+			t.Errorf("loop %v accepted", aliases)
+		}
+	}
+}
+
+// deprecatedOper is being phased out
+type deprecatedOper struct {
+	testOper
+	since, sunset time.Time
+}
+
+func (o deprecatedOper) DeprecatedSince() time.Time { return o.since }
+func (o deprecatedOper) SunsetAt() time.Time        { return o.sunset }
+
+func TestDeprecatable(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 1, 1, 12, 0, 0, 0, time.FixedZone("CAT", 2*3600))
+	h, rec := loggedHandler(t, Config{}, testMS{
+		"old":     deprecatedOper{testOper{handle: result("old")}, since, sunset},
+		"retired": deprecatedOper{testOper{handle: result("retired")}, since, time.Time{}},
+		"current": deprecatedOper{testOper{handle: result("current")}, time.Time{}, time.Time{}},
+		"new":     testOper{handle: result("new")},
+	})
+	for target, want := range map[string][2]string{
+		"/old":     {"true", "Fri, 01 Jan 2027 10:00:00 GMT"},
+		"/retired": {"true", ""},
+		"/current": {"", ""},
+		"/new":     {"", ""},
+	} {
+		w := do(h, http.MethodGet, target, "")
+		if got := [2]string{w.Header().Get(DeprecationHeader), w.Header().Get(SunsetHeader)}; w.Code != http.StatusOK || got != want {
+			t.Errorf("%s got %d with deprecation headers %q, want %q", target, w.Code, got, want)
+		}
+	}
+	if !rec.contains("deprecated operation old called") || rec.contains("deprecated operation new called") {
+		t.Fatalf("deprecated calls not logged: %q", rec.lines)
+	}
+}
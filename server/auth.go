@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/go-msvc/ms"
+)
+
+// Authenticator identifies the caller of a request
+// an error rejects the request with 401 Unauthorized
+type Authenticator func(httpReq *http.Request) (principal interface{}, err error)
+
+// Authorizer decides whether the caller may invoke an operation, after authentication,
+// decoding and validation, with the principal available from Principal(ctx)
+// an error rejects the request with 403 Forbidden
+type Authorizer func(ctx ms.Context, operName string, req interface{}) error
+
+// AuthError is returned by an Authenticator to set the WWW-Authenticate challenge
+type AuthError struct {
+	Challenge string
+	Message   string
+}
+
+func (e AuthError) Error() string {
+	return e.Message
+}
+
+const defaultAuthChallenge = `Basic realm="restricted"`
+
+// Principal returns the principal set by the Authenticator for the request being handled
+func Principal(ctx context.Context) interface{} {
+	return ctx.Value(principalKey)
+}
+
+// BasicAuthenticator authenticates with HTTP Basic credentials checked by verify
+// the principal is the username
+func BasicAuthenticator(realm string, verify func(username, password string) bool) Authenticator {
+	challenge := fmt.Sprintf("Basic realm=%q", realm)
+	return func(httpReq *http.Request) (interface{}, error) {
+		username, password, ok := httpReq.BasicAuth()
+		if !ok {
+			return nil, AuthError{Challenge: challenge, Message: "missing credentials"}
+		}
+		if !verify(username, password) {
+			return nil, AuthError{Challenge: challenge, Message: "invalid credentials"}
+		}
+		return username, nil
+	}
+}
+
+// BasicCredentials returns a verify function for BasicAuthenticator
+// that accepts the given username->password pairs
+func BasicCredentials(passwords map[string]string) func(username, password string) bool {
+	return func(username, password string) bool {
+		expected, ok := passwords[username]
+		if !ok {
+			expected = password //compare anyway to not reveal valid usernames by timing
+		}
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1 && ok
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+func TestBasicAuthenticator(t *testing.T) {
+	h := testHandler(t, Config{
+		Authenticator: BasicAuthenticator("billing", BasicCredentials(map[string]string{"svc": "secret"})),
+	}, testMS{"whoami": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		return Principal(ctx), nil
+	}}})
+
+	basic := func(username, password string) string {
+		httpReq, _ := http.NewRequest(http.MethodGet, "/", nil)
+		httpReq.SetBasicAuth(username, password)
+		return httpReq.Header.Get("Authorization")
+	}
+	for name, header := range map[string][]string{
+		"missing":        nil,
+		"wrong password": {"Authorization", basic("svc", "guess")},
+		"unknown user":   {"Authorization", basic("other", "secret")},
+	} {
+		w := do(h, http.MethodGet, "/whoami", "", header...)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Basic realm="billing"` {
+			t.Fatalf("%s credentials got %d %v", name, w.Code, w.Header())
+		}
+	}
+	if w := do(h, http.MethodGet, "/whoami", "", "Authorization", basic("svc", "secret")); w.Code != http.StatusOK || w.Body.String() != `"svc"` {
+		t.Fatalf("valid credentials got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	called := 0
+	handle := func(ms.Context, interface{}) (interface{}, error) {
+		called++
+		return "done", nil
+	}
+	h := testHandler(t, Config{
+		Authenticator: func(httpReq *http.Request) (interface{}, error) {
+			if user := httpReq.Header.Get("X-User"); user != "" {
+				return user, nil
+			}
+			return nil, errors.New("no user")
+		},
+		Authorizer: func(ctx ms.Context, operName string, req interface{}) error {
+			if operName == "purge" && Principal(ctx) != "root" {
+				return errors.New("purge is for root only")
+			}
+			return nil
+		},
+	}, testMS{"purge": testOper{handle: handle}, "status": testOper{handle: handle}})
+
+	for _, c := range []struct {
+		user, target string
+		want         int
+	}{
+		{"", "/status", http.StatusUnauthorized},
+		{"bob", "/status", http.StatusOK},
+		{"bob", "/purge", http.StatusForbidden},
+		{"root", "/purge", http.StatusOK},
+	} {
+		var header []string
+		if c.user != "" {
+			header = []string{"X-User", c.user}
+		}
+		if w := do(h, http.MethodPost, c.target, "", header...); w.Code != c.want {
+			t.Fatalf("%q %s got %d %s, want %d", c.user, c.target, w.Code, w.Body, c.want)
+		}
+	}
+	if called != 2 {
+		t.Fatalf("handler called %d times, want 2", called)
+	}
+
+	//a dry run must not tell a forbidden caller that the request is valid
+	if w := do(h, http.MethodPost, "/purge", "", "X-User", "bob", "X-Dry-Run", "true"); w.Code != http.StatusForbidden {
+		t.Fatalf("forbidden dry run got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/purge", "", "X-User", "root", "X-Dry-Run", "true"); w.Code != http.StatusOK || called != 2 {
+		t.Fatalf("allowed dry run got %d %s, handler called %d times", w.Code, w.Body, called)
+	}
+}
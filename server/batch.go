@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// defaultMaxBatchSize is used when Config.MaxBatchSize is not set
+const defaultMaxBatchSize = 20
+
+// BatchItem is one operation invocation in a batch request body
+// Method defaults to POST
+type BatchItem struct {
+	Operation string          `json:"operation"`
+	Method    string          `json:"method,omitempty"`
+	Body      json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the response of one BatchItem, in the same order as the request
+// Body is the JSON result or error body, or a JSON string for other content types
+type BatchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// serveBatch dispatches each item of a batch through ServeHTTP as if it was sent on its own
+// with the headers of the batch request, an item that fails does not fail the other items
+func (s *server) serveBatch(httpRes http.ResponseWriter, httpReq *http.Request) {
+	if httpReq.Method != http.MethodPost {
+		httpRes.Header().Set("Allow", http.MethodPost)
+		s.config.ErrorWriter(httpRes, httpReq, http.StatusMethodNotAllowed, withErrorCode(ErrorCodeMethodNotAllowed, errors.Errorc(http.StatusMethodNotAllowed, "batch requires POST")))
+		return
+	}
+	body := httpReq.Body
+	if s.config.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(httpRes, body, s.config.MaxBodyBytes)
+	}
+	var items []BatchItem
+	if err := json.NewDecoder(body).Decode(&items); err != nil {
+		if isMaxBytesError(err) {
+			s.config.ErrorWriter(httpRes, httpReq, http.StatusRequestEntityTooLarge, errors.Errorc(http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", s.config.MaxBodyBytes)))
+			return
+		}
+		s.config.ErrorWriter(httpRes, httpReq, http.StatusBadRequest, withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid batch, expecting [{\"operation\":...,\"body\":...}]: %v", err))))
+		return
+	}
+	if len(items) > s.config.MaxBatchSize {
+		s.config.ErrorWriter(httpRes, httpReq, http.StatusRequestEntityTooLarge, errors.Errorc(http.StatusRequestEntityTooLarge, fmt.Sprintf("batch of %d items exceeds %d", len(items), s.config.MaxBatchSize)))
+		return
+	}
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i] = s.serveBatchItem(httpReq, i, item)
+	}
+	resBody, err := json.Marshal(results)
+	if err != nil {
+		s.config.ErrorWriter(httpRes, httpReq, http.StatusInternalServerError, errors.Wrapf(err, "failed to encode batch results"))
+		return
+	}
+	httpRes.Header().Set("Content-Type", "application/json")
+	httpRes.Header().Set("Content-Length", strconv.Itoa(len(resBody)))
+	httpRes.WriteHeader(http.StatusOK)
+	if _, err := httpRes.Write(resBody); err != nil {
+		s.log.Errorf("failed to write batch results: %+v", err)
+	}
+}
+
+// serveBatchItem serves item i of a batch, with an Idempotency-Key of the batch
+// extended with the index so that each item is replayed on its own
+func (s *server) serveBatchItem(batchReq *http.Request, i int, item BatchItem) BatchResult {
+	if item.Operation == "" || strings.HasPrefix(item.Operation, "/") || s.builtin("/"+item.Operation) != nil {
+		return s.batchItemError(batchReq, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid batch operation %q", item.Operation)))
+	}
+	method := item.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	httpReq, err := http.NewRequestWithContext(batchReq.Context(), method, "/"+item.Operation, bytes.NewReader(item.Body))
+	if err != nil {
+		return s.batchItemError(batchReq, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid batch item: %v", err)))
+	}
+	httpReq.Header = batchReq.Header.Clone()
+	httpReq.Header.Del(RequestIDHeader)
+	httpReq.Header.Del("Content-Length")
+	httpReq.Header.Del("Content-Encoding")
+	httpReq.Header.Del("Accept-Encoding")
+	if key := batchReq.Header.Get(IdempotencyKeyHeader); key != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, key+"/"+strconv.Itoa(i))
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.RemoteAddr = batchReq.RemoteAddr
+	httpReq.TLS = batchReq.TLS
+	httpReq.Host = batchReq.Host
+
+	rec := &batchRecorder{header: http.Header{}}
+	s.ServeHTTP(rec, httpReq)
+	return rec.result()
+}
+
+// batchItemError is the result of an item that could not be dispatched, written by the ErrorWriter
+func (s *server) batchItemError(batchReq *http.Request, err error) BatchResult {
+	rec := &batchRecorder{header: http.Header{}}
+	s.config.ErrorWriter(rec, batchReq, http.StatusBadRequest, err)
+	return rec.result()
+}
+
+// batchRecorder keeps the response of a batch item in memory
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *batchRecorder) Header() http.Header { return r.header }
+
+func (r *batchRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *batchRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func (r *batchRecorder) result() BatchResult {
+	result := BatchResult{Status: r.status, Body: r.body.Bytes()}
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	if len(result.Body) > 0 && !json.Valid(result.Body) {
+		result.Body, _ = json.Marshal(r.body.String())
+	}
+	return result
+}
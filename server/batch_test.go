@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// batchResults decodes the results of a batch response
+func batchResults(t *testing.T, body []byte) []BatchResult {
+	t.Helper()
+	var results []BatchResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		t.Fatalf("invalid batch response %s: %+v", body, err)
+	}
+	return results
+}
+
+func TestBatch(t *testing.T) {
+	h := testHandler(t, Config{BatchPath: "/batch", MaxBatchSize: 4}, testMS{
+		"create":  bodyOper{testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo}},
+		"getUser": methodsOper{testOper{handle: result("user")}, []string{http.MethodGet}},
+	})
+	w := do(h, http.MethodPost, "/batch", `[
+		{"operation":"create","body":{"name":"a"}},
+		{"operation":"create"},
+		{"operation":"getUser","method":"GET"},
+		{"operation":"batch"}
+	]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	results := batchResults(t, w.Body.Bytes())
+	want := []BatchResult{
+		{Status: http.StatusOK, Body: json.RawMessage(`{"name":"a"}`)},
+		{Status: http.StatusBadRequest},
+		{Status: http.StatusOK, Body: json.RawMessage(`"user"`)},
+		{Status: http.StatusBadRequest},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results: %s", len(results), w.Body)
+	}
+	for i := range want {
+		if results[i].Status != want[i].Status || (want[i].Body != nil && strings.TrimSpace(string(results[i].Body)) != string(want[i].Body)) {
+			t.Errorf("item %d got %d %s", i, results[i].Status, results[i].Body)
+		}
+	}
+	if w := do(h, http.MethodPost, "/batch", `[{},{},{},{},{}]`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("too many items got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/batch", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET got %d", w.Code)
+	}
+}
+
+func TestBatchBodyLimit(t *testing.T) {
+	svc := testMS{"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo}}
+	batch := `[{"operation":"create","body":{"name":"` + strings.Repeat("a", 64) + `"}}]`
+	if w := do(testHandler(t, Config{BatchPath: "/batch", MaxBodyBytes: 32}, svc), http.MethodPost, "/batch", batch); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("limited got %d", w.Code)
+	}
+	w := do(testHandler(t, Config{BatchPath: "/batch", MaxBodyBytes: -1}, svc), http.MethodPost, "/batch", batch)
+	if results := batchResults(t, w.Body.Bytes()); w.Code != http.StatusOK || results[0].Status != http.StatusOK {
+		t.Fatalf("unlimited got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestBatchIdempotency(t *testing.T) {
+	var calls int32
+	h := testHandler(t, Config{BatchPath: "/batch", Idempotency: &IdempotencyConfig{}}, testMS{
+		"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: counter(&calls)},
+	})
+	batch := `[{"operation":"create","body":{"name":"a"}},{"operation":"create","body":{"name":"a"}}]`
+	first := batchResults(t, do(h, http.MethodPost, "/batch", batch, IdempotencyKeyHeader, "k1").Body.Bytes())
+	if calls != 2 || string(first[0].Body) == string(first[1].Body) {
+		t.Fatalf("items shared a key: %d calls, results %+v", calls, first)
+	}
+	retry := batchResults(t, do(h, http.MethodPost, "/batch", batch, IdempotencyKeyHeader, "k1").Body.Bytes())
+	if calls != 2 || !reflect.DeepEqual(first, retry) {
+		t.Fatalf("retry ran %d calls, results %+v", calls, retry)
+	}
+}
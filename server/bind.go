@@ -0,0 +1,171 @@
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// bindValues sets the fields of structValue that are tagged with tagName
+// from the values returned by lookup for the tagged name.
+// Fields without the tag or with no values are left unchanged,
+// unless the tag has the required option, e.g. `header:"X-Tenant-ID,required"`.
+func bindValues(structValue reflect.Value, tagName string, lookup func(name string) []string) error {
+	if structValue.Kind() != reflect.Struct {
+		return nil
+	}
+	t := structValue.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue //unexported
+		}
+		options := strings.Split(f.Tag.Get(tagName), ",")
+		name := options[0]
+		if name == "" || name == "-" {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				if err := bindValues(structValue.Field(i), tagName, lookup); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.Type == fileHeaderType || (f.Type.Kind() == reflect.Slice && f.Type.Elem() == fileHeaderType) {
+			continue //see bindFiles()
+		}
+		values := lookup(name)
+		if len(values) == 0 {
+			for _, option := range options[1:] {
+				if option == "required" {
+					return errors.Errorf("missing required %s %s", tagName, name)
+				}
+			}
+			continue
+		}
+		if err := setValues(structValue.Field(i), values); err != nil {
+			return errors.Wrapf(err, "invalid %s %s", tagName, name)
+		}
+	}
+	return nil
+}
+
+// bindJSONNames sets the fields of structValue from the values returned by lookup
+// for their JSON name, skipping fields bound by other tags and types that
+// cannot be set from a string, which can then only be set from the body
+func bindJSONNames(structValue reflect.Value, lookup func(name string) []string) error {
+	if structValue.Kind() != reflect.Struct {
+		return nil
+	}
+	t := structValue.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue //unexported
+		}
+		isParameter := false
+		for tag := range parameterTags {
+			if name := strings.Split(f.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+				isParameter = true
+			}
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if isParameter || name == "-" {
+			continue
+		}
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := bindJSONNames(structValue.Field(i), lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !stringSettable(f.Type) {
+			continue
+		}
+		if values := lookup(name); len(values) > 0 {
+			if err := setValues(structValue.Field(i), values); err != nil {
+				return errors.Wrapf(err, "invalid query %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+// stringSettable is true for the types that setValues supports
+func stringSettable(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		if t.Elem().Kind() == reflect.Uint8 {
+			return false //[]byte is base64 in JSON
+		}
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.Errorf("%q is not a bool", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a %v", s, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a %v", s, v.Type())
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a %v", s, v.Type())
+		}
+		v.SetFloat(f)
+	default:
+		return errors.Errorf("cannot set %v from a string", v.Type())
+	}
+	return nil
+}
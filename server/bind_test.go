@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+type queryReq struct {
+	ID     int      `query:"id" json:"id"`
+	Active *bool    `query:"active" json:"active"`
+	Score  float64  `query:"score" json:"score"`
+	Tags   []string `query:"tag" json:"tags"`
+	Name   string   `query:"name" json:"name"`
+}
+
+// capture returns a handler that stores its request in *req
+func capture(req *interface{}) func(ms.Context, interface{}) (interface{}, error) {
+	return func(_ ms.Context, r interface{}) (interface{}, error) {
+		*req = r
+		return nil, nil
+	}
+}
+
+func TestQueryBinding(t *testing.T) {
+	var got interface{}
+	h := testHandler(t, Config{}, testMS{"getUser": testOper{reqType: reflect.TypeOf(queryReq{}), handle: capture(&got)}})
+
+	active := true
+	for target, want := range map[string]queryReq{
+		"/getUser?id=123&active=true&score=1.5": {ID: 123, Active: &active, Score: 1.5},
+		"/getUser?tag=a&tag=b":                  {Tags: []string{"a", "b"}},
+		"/getUser":                              {},
+	} {
+		if w := do(h, http.MethodGet, target, ""); w.Code != http.StatusNoContent {
+			t.Fatalf("%s got %d %s", target, w.Code, w.Body)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s bound %+v, expected %+v", target, got, want)
+		}
+	}
+
+	for _, target := range []string{"/getUser?id=abc", "/getUser?active=maybe", "/getUser?score=x"} {
+		if w := do(h, http.MethodGet, target, ""); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s got %d, expected 400", target, w.Code)
+		}
+	}
+
+	//the body wins over the query
+	if w := do(h, http.MethodPost, "/getUser?id=1&name=query", `{"name":"body"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if req := got.(queryReq); req.ID != 1 || req.Name != "body" {
+		b, _ := json.Marshal(req)
+		t.Fatalf("bound %s", b)
+	}
+}
+
+type tenantReq struct {
+	Tenant  string `header:"X-Tenant-ID,required" json:"tenant"`
+	Version int    `header:"X-Api-Version" json:"version"`
+	Name    string `json:"name"`
+}
+
+// Validate runs after the headers are bound
+func (r tenantReq) Validate() error {
+	if r.Tenant == "blocked" {
+		return errors.Error("blocked tenant")
+	}
+	return nil
+}
+
+func TestHeaderBinding(t *testing.T) {
+	var got interface{}
+	h := testHandler(t, Config{}, testMS{"create": testOper{reqType: reflect.TypeOf(tenantReq{}), handle: capture(&got)}})
+
+	if w := do(h, http.MethodPost, "/create", `{"name":"x"}`, "X-Tenant-ID", "acme", "X-Api-Version", "2"); w.Code != http.StatusNoContent {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if want := (tenantReq{Tenant: "acme", Version: 2, Name: "x"}); got != want {
+		t.Fatalf("bound %+v", got)
+	}
+	if w := do(h, http.MethodPost, "/create", `{}`, "X-Tenant-ID", "acme"); w.Code != http.StatusNoContent || got.(tenantReq).Version != 0 {
+		t.Fatalf("missing optional header got %d %+v", w.Code, got)
+	}
+	for name, header := range map[string][]string{
+		"missing required": nil,
+		"invalid int":      {"X-Tenant-ID", "acme", "X-Api-Version", "two"},
+		"failed validator": {"X-Tenant-ID", "blocked"},
+	} {
+		if w := do(h, http.MethodPost, "/create", `{}`, header...); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s got %d", name, w.Code)
+		}
+	}
+}
+
+// findReq is bound by JSON name from a query when its operation accepts either
+type findReq struct {
+	Name  string   `json:"name"`
+	Limit *int     `json:"limit,omitempty"`
+	Tags  []string `json:"tags"`
+	Page  int      `query:"p" json:"page"`
+}
+
+// queryBodyOper accepts its request from the query or the body
+type queryBodyOper struct{ testOper }
+
+func (queryBodyOper) QueryBody() bool { return true }
+
+func TestQueryBody(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"find":   queryBodyOper{testOper{reqType: reflect.TypeOf(findReq{}), handle: echo}},
+		"search": testOper{reqType: reflect.TypeOf(findReq{}), handle: echo},
+	})
+	get := do(h, http.MethodGet, "/find?name=a&limit=2&tags=x&tags=y&p=3", "")
+	post := do(h, http.MethodPost, "/find", `{"name":"a","limit":2,"tags":["x","y"],"page":3}`)
+	if get.Code != http.StatusOK || post.Code != http.StatusOK || get.Body.String() != post.Body.String() ||
+		get.Body.String() != `{"name":"a","limit":2,"tags":["x","y"],"page":3}` {
+		t.Fatalf("GET got %d %s, POST got %d %s", get.Code, get.Body, post.Code, post.Body)
+	}
+	//the body takes precedence and the query fills in the other fields
+	if w := do(h, http.MethodPost, "/find?name=q&limit=5", `{"limit":3}`); w.Body.String() != `{"name":"q","limit":3,"tags":null,"page":0}` {
+		t.Fatalf("merged got %s", w.Body)
+	}
+	//a query tag replaces the JSON name
+	if w := do(h, http.MethodGet, "/find?page=3", ""); w.Body.String() != `{"name":"","tags":null,"page":0}` {
+		t.Fatalf("JSON name of a tagged field got %s", w.Body)
+	}
+	if w := do(h, http.MethodGet, "/find?limit=few", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid query value got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/search?name=a", ""); w.Body.String() != `{"name":"","tags":null,"page":0}` {
+		t.Fatalf("operation without QueryBody bound %s", w.Body)
+	}
+}
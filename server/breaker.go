@@ -0,0 +1,177 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-msvc/errors"
+)
+
+// CircuitBreakerConfig opens a circuit per operation after consecutive failures
+// so that a failing downstream dependency is not called while it recovers
+type CircuitBreakerConfig struct {
+	// Failures is the number of consecutive handler failures that open the circuit,
+	// a failure is a handler error with status >= 500 or a handler timeout
+	Failures int
+	// CoolDown is how long the circuit stays open before one request is let through,
+	// its success closes the circuit and its failure opens it again
+	CoolDown time.Duration
+	// Operations limits the breaker to these operation names, empty applies it to all
+	Operations []string
+}
+
+func (c CircuitBreakerConfig) Validate() error {
+	if c.Failures < 1 {
+		return errors.Errorf("failures:%d must be at least 1", c.Failures)
+	}
+	if c.CoolDown <= 0 {
+		return errors.Errorf("coolDown:%v must be positive", c.CoolDown)
+	}
+	return nil
+}
+
+// BreakerState is the state of the circuit breaker of an operation
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota //requests are handled
+	BreakerOpen                         //requests are rejected with 503
+	BreakerHalfOpen                     //one request is handled to test the dependency
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerObserver is optionally implemented by the MetricsCollector
+// to be told when the circuit breaker of an operation changes state
+type BreakerObserver interface {
+	ObserveBreaker(operName string, state BreakerState)
+}
+
+type breakers struct {
+	config     CircuitBreakerConfig
+	operations map[string]bool //nil for all operations
+	observer   BreakerObserver //nil when not observed
+	mutex      sync.Mutex
+	circuits   map[string]*circuit
+}
+
+type circuit struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool //set while the half-open request is in progress
+}
+
+func newBreakers(c CircuitBreakerConfig, metrics MetricsCollector) *breakers {
+	b := &breakers{
+		config:   c,
+		circuits: map[string]*circuit{},
+	}
+	if len(c.Operations) > 0 {
+		b.operations = map[string]bool{}
+		for _, operName := range c.Operations {
+			b.operations[operName] = true
+		}
+	}
+	b.observer, _ = metrics.(BreakerObserver)
+	return b
+}
+
+// applies is true when the operation is protected by a breaker
+func (b *breakers) applies(operName string) bool {
+	return b.operations == nil || b.operations[operName]
+}
+
+// allow is false while the circuit of the operation is open, with the time left until it half-opens
+// in the half-open state only one request at a time is allowed, for which trial is true
+func (b *breakers) allow(operName string, now time.Time) (allowed bool, trial bool, wait time.Duration) {
+	b.mutex.Lock()
+	c, ok := b.circuits[operName]
+	if !ok {
+		c = &circuit{}
+		b.circuits[operName] = c
+	}
+	changed := false
+	if c.state == BreakerOpen {
+		if wait := c.openedAt.Add(b.config.CoolDown).Sub(now); wait > 0 {
+			b.mutex.Unlock()
+			return false, false, wait
+		}
+		c.state = BreakerHalfOpen
+		changed = true
+	}
+	if c.state == BreakerHalfOpen {
+		if c.trial {
+			b.mutex.Unlock()
+			return false, false, time.Second
+		}
+		c.trial = true
+		trial = true
+	}
+	state := c.state
+	b.mutex.Unlock()
+	if changed {
+		b.observe(operName, state)
+	}
+	return true, trial, 0
+}
+
+// record updates the circuit of the operation with the outcome of an allowed request
+// only the trial request decides a half-open circuit, so the outcome of a request
+// that was allowed before the circuit opened does not change it
+func (b *breakers) record(operName string, trial bool, failed bool, now time.Time) {
+	b.mutex.Lock()
+	c := b.circuits[operName]
+	previous := c.state
+	switch {
+	case trial:
+		c.trial = false
+		if failed {
+			c.state = BreakerOpen
+			c.openedAt = now
+		} else {
+			c.failures = 0
+			c.state = BreakerClosed
+		}
+	case c.state != BreakerClosed:
+	case failed:
+		c.failures++
+		if c.failures >= b.config.Failures {
+			c.state = BreakerOpen
+			c.openedAt = now
+		}
+	default:
+		c.failures = 0
+	}
+	state := c.state
+	b.mutex.Unlock()
+	if state != previous {
+		b.observe(operName, state)
+	}
+}
+
+// release ends an allowed request without an outcome, e.g. when the client closed it,
+// so a half-open circuit lets the next request through as its trial
+func (b *breakers) release(operName string, trial bool) {
+	if !trial {
+		return
+	}
+	b.mutex.Lock()
+	b.circuits[operName].trial = false
+	b.mutex.Unlock()
+}
+
+func (b *breakers) observe(operName string, state BreakerState) {
+	if b.observer != nil {
+		b.observer.ObserveBreaker(operName, state)
+	}
+}
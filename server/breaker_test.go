@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := true
+	calls := 0
+	metrics := NewPrometheusMetrics()
+	h := testHandler(t, Config{Metrics: metrics, CircuitBreaker: &CircuitBreakerConfig{Failures: 2, CoolDown: 50 * time.Millisecond}}, testMS{
+		"charge": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			calls++
+			if failing {
+				return nil, errors.Errorc(http.StatusBadGateway, "payment provider down")
+			}
+			return "charged", nil
+		}},
+		"refund": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorc(http.StatusBadRequest, "nothing to refund")
+		}},
+	})
+
+	for i := 0; i < 3; i++ {
+		do(h, http.MethodPost, "/refund", "")
+	}
+	if state := metrics.BreakerState("refund"); state != BreakerClosed {
+		t.Fatalf("client errors made the circuit %v", state)
+	}
+
+	do(h, http.MethodPost, "/charge", "")
+	do(h, http.MethodPost, "/charge", "")
+	w := do(h, http.MethodPost, "/charge", "")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" || calls != 2 || metrics.BreakerState("charge") != BreakerOpen {
+		t.Fatalf("open circuit got %d %v after %d calls in state %v", w.Code, w.Header(), calls, metrics.BreakerState("charge"))
+	}
+	time.Sleep(60 * time.Millisecond)
+	if w := do(h, http.MethodPost, "/charge", ""); w.Code != http.StatusBadGateway || calls != 3 || metrics.BreakerState("charge") != BreakerOpen {
+		t.Fatalf("failed trial got %d after %d calls in state %v", w.Code, calls, metrics.BreakerState("charge"))
+	}
+	time.Sleep(60 * time.Millisecond)
+	failing = false
+	if w := do(h, http.MethodPost, "/charge", ""); w.Code != http.StatusOK || metrics.BreakerState("charge") != BreakerClosed {
+		t.Fatalf("successful trial got %d in state %v", w.Code, metrics.BreakerState("charge"))
+	}
+
+	metricsRes := httptest.NewRecorder()
+	metrics.ServeHTTP(metricsRes, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(metricsRes.Body.String(), `http_circuit_breaker_state{operation="charge"} 0`) {
+		t.Fatalf("breaker state not exported: %s", metricsRes.Body)
+	}
+}
+
+func TestCircuitBreakerClientClosed(t *testing.T) {
+	calls := 0
+	metrics := NewPrometheusMetrics()
+	h := testHandler(t, Config{Metrics: metrics, CircuitBreaker: &CircuitBreakerConfig{Failures: 1, CoolDown: 20 * time.Millisecond}}, testMS{
+		"charge": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			calls++
+			return "charged", nil
+		}},
+	})
+	closed, cancel := context.WithCancel(context.Background())
+	cancel()
+	b := h.(*server).breakers
+	b.allow("charge", time.Now())
+	b.record("charge", false, true, time.Now())
+	time.Sleep(30 * time.Millisecond)
+
+	//the trial request is closed by the client, so the next request is the trial
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/charge", nil).WithContext(closed))
+	if state := metrics.BreakerState("charge"); calls != 1 || state != BreakerHalfOpen {
+		t.Fatalf("closed trial left the circuit %v after %d calls", state, calls)
+	}
+	if w := do(h, http.MethodPost, "/charge", ""); w.Code != http.StatusOK || metrics.BreakerState("charge") != BreakerClosed {
+		t.Fatalf("next trial got %d in state %v", w.Code, metrics.BreakerState("charge"))
+	}
+}
+
+func TestBreakerTrial(t *testing.T) {
+	b := newBreakers(CircuitBreakerConfig{Failures: 1, CoolDown: time.Second}, nil)
+	now := time.Now()
+	if allowed, trial, _ := b.allow("charge", now); !allowed || trial {
+		t.Fatalf("closed circuit allowed:%v trial:%v", allowed, trial)
+	}
+	//a slow request allowed while the circuit was closed
+	b.allow("charge", now)
+	b.record("charge", false, true, now)
+	if allowed, _, wait := b.allow("charge", now); allowed || wait != time.Second {
+		t.Fatalf("open circuit allowed:%v wait:%v", allowed, wait)
+	}
+
+	//its late success neither closes the open circuit nor ends the half-open trial
+	b.record("charge", false, false, now)
+	if allowed, _, _ := b.allow("charge", now.Add(time.Second/2)); allowed {
+		t.Fatal("late success closed the circuit")
+	}
+	later := now.Add(time.Second)
+	if allowed, trial, _ := b.allow("charge", later); !allowed || !trial {
+		t.Fatalf("half-open circuit allowed:%v trial:%v", allowed, trial)
+	}
+	b.record("charge", false, false, later)
+	if allowed, _, _ := b.allow("charge", later); allowed {
+		t.Fatal("late success ended the trial")
+	}
+
+	//a trial without an outcome lets the next request try
+	b.release("charge", true)
+	if allowed, trial, _ := b.allow("charge", later); !allowed || !trial {
+		t.Fatalf("released trial allowed:%v trial:%v", allowed, trial)
+	}
+	b.record("charge", true, false, later)
+	if allowed, trial, _ := b.allow("charge", later); !allowed || trial || b.circuits["charge"].state != BreakerClosed {
+		t.Fatalf("successful trial left allowed:%v trial:%v in state %v", allowed, trial, b.circuits["charge"].state)
+	}
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-msvc/errors"
+)
+
+// values of Config.JSONKeyCase
+const (
+	JSONKeyCaseSnake = "snake"
+	JSONKeyCaseCamel = "camel"
+)
+
+// keyCaser returns the function renaming JSON object keys for Config.JSONKeyCase
+func keyCaser(keyCase string) (func(string) string, error) {
+	switch keyCase {
+	case JSONKeyCaseSnake:
+		return snakeCase, nil
+	case JSONKeyCaseCamel:
+		return camelCase, nil
+	}
+	return nil, errors.Errorf("invalid jsonKeyCase:%q, expecting snake|camel", keyCase)
+}
+
+// keyWords splits a name like "userID", "HTTPServer" or "user_name" into words
+func keyWords(name string) []string {
+	words := []string{}
+	runes := []rune(name)
+	start := 0
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			prev := runes[i-1]
+			acronymEnd := unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || acronymEnd {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+func snakeCase(name string) string {
+	words := keyWords(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
+
+func camelCase(name string) string {
+	words := keyWords(name)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if i > 0 {
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			word = string(runes)
+		}
+		words[i] = word
+	}
+	return strings.Join(words, "")
+}
+
+// renamedObject is a struct encoded with renamed field names, in field order
+type renamedObject []renamedField
+
+type renamedField struct {
+	name  string
+	value interface{}
+}
+
+func (o renamedObject) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.name)
+		out.Write(name)
+		out.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// renameKeys returns v to encode with encoding/json with the field names of structs renamed,
+// also those set by json tags, while map keys and values with their own MarshalJSON
+// or MarshalText, e.g. time.Time or json.RawMessage, are encoded unchanged
+func renameKeys(v reflect.Value, rename func(string) string) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	if v.CanAddr() && (reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return renameKeys(v.Elem(), rename)
+	case reflect.Struct:
+		object := renamedObject{}
+		for _, field := range jsonFields(t, rename) {
+			fv, ok := fieldByIndex(v, field.index)
+			if !ok || (field.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			var value interface{}
+			if field.quoted && !(fv.Kind() == reflect.Ptr && fv.IsNil()) {
+				encoded, _ := json.Marshal(reflect.Indirect(fv).Interface())
+				value = string(encoded)
+			} else if !field.quoted {
+				value = renameKeys(fv, rename)
+			}
+			object = append(object, renamedField{name: field.name, value: value})
+		}
+		return object
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := reflect.MakeMapWithSize(reflect.MapOf(t.Key(), reflect.TypeOf((*interface{})(nil)).Elem()), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if value := renameKeys(iter.Value(), rename); value != nil {
+				m.SetMapIndex(iter.Key(), reflect.ValueOf(value))
+			} else {
+				m.SetMapIndex(iter.Key(), reflect.Zero(m.Type().Elem()))
+			}
+		}
+		return m.Interface()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || t.Elem().Kind() == reflect.Uint8) {
+			return v.Interface() //null or base64
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = renameKeys(v.Index(i), rename)
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+// jsonField is a field of a struct as encoded by encoding/json
+type jsonField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+// jsonFields lists the encoded fields of a struct type with the fields of embedded structs and renamed names,
+// where like encoding/json the shallowest field of a name wins, or the only tagged one at that depth
+func jsonFields(t reflect.Type, rename func(string) string) []jsonField {
+	fields := []jsonField{}
+	embedding := map[reflect.Type]bool{} //types on the path, as a pointer can embed a struct in itself
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		embedding[t] = true
+		defer delete(embedding, t)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if f.PkgPath != "" && !(f.Anonymous && ft.Kind() == reflect.Struct) {
+				continue //unexported, but the exported fields of an embedded struct are encoded
+			}
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			options := strings.Split(tag, ",")
+			fieldIndex := append(append([]int{}, index...), i)
+			if options[0] == "" && f.Anonymous && ft.Kind() == reflect.Struct {
+				if !embedding[ft] {
+					collect(ft, fieldIndex)
+				}
+				continue
+			}
+			field := jsonField{name: options[0], index: fieldIndex, tagged: options[0] != ""}
+			if field.name == "" {
+				field.name = f.Name
+			}
+			field.name = rename(field.name)
+			for _, option := range options[1:] {
+				switch option {
+				case "omitempty":
+					field.omitEmpty = true
+				case "string":
+					switch ft.Kind() {
+					case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+						field.quoted = true
+					}
+				}
+			}
+			fields = append(fields, field)
+		}
+	}
+	collect(t, nil)
+
+	dominant := []jsonField{}
+	for i, field := range fields {
+		wins := true
+		for j, other := range fields {
+			if i == j || other.name != field.name {
+				continue
+			}
+			if len(other.index) < len(field.index) ||
+				(len(other.index) == len(field.index) && (other.tagged || !field.tagged)) {
+				wins = false
+				break
+			}
+		}
+		if wins {
+			dominant = append(dominant, field)
+		}
+	}
+	return dominant
+}
+
+// fieldByIndex is v.FieldByIndex that is false for a field in a nil embedded struct pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, fieldIndex := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(fieldIndex)
+	}
+	return v, true
+}
+
+// isEmptyValue is the omitempty test of encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// newKeyCaseEncoder returns a JSON Encoder that renames the fields of structs, also those set by json tags
+func newKeyCaseEncoder(rename func(string) string, pretty bool) Encoder {
+	return func(res interface{}) ([]byte, error) {
+		renamed := renameKeys(reflect.ValueOf(res), rename)
+		if pretty {
+			return json.MarshalIndent(renamed, "", "  ")
+		}
+		return json.Marshal(renamed)
+	}
+}
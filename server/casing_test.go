@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// untaggedRes leaks its Go field names without JSONKeyCase
+type untaggedRes struct {
+	UserID    int
+	FirstName string
+	HTTPAddr  string
+	Labels    map[string]bool
+	Friends   []untaggedRes `json:",omitempty"`
+}
+
+func TestJSONKeyCase(t *testing.T) {
+	res := untaggedRes{UserID: 1, FirstName: "a\u003cb", HTTPAddr: "x", Labels: map[string]bool{"IsAdmin": true}, Friends: []untaggedRes{{UserID: 2}}}
+	for keyCase, want := range map[string]string{
+		"":      `{"UserID":1,"FirstName":"a\u003cb","HTTPAddr":"x","Labels":{"IsAdmin":true},"Friends":[{"UserID":2,"FirstName":"","HTTPAddr":"","Labels":null}]}`,
+		"snake": `{"user_id":1,"first_name":"a\u003cb","http_addr":"x","labels":{"IsAdmin":true},"friends":[{"user_id":2,"first_name":"","http_addr":"","labels":null}]}`,
+		"camel": `{"userId":1,"firstName":"a\u003cb","httpAddr":"x","labels":{"IsAdmin":true},"friends":[{"userId":2,"firstName":"","httpAddr":"","labels":null}]}`,
+	} {
+		h := testHandler(t, Config{JSONKeyCase: keyCase}, testMS{"user": testOper{handle: result(res)}})
+		if w := do(h, http.MethodGet, "/user", ""); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%q got %d %s", keyCase, w.Code, w.Body)
+		}
+	}
+	if err := (Config{Addr: "localhost", JSONKeyCase: "kebab"}).Validate(); err == nil {
+		t.Fatal("invalid jsonKeyCase accepted")
+	}
+}
+
+type recordFields struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Revision  int
+}
+
+type taggedRes struct {
+	recordFields
+	*Owner
+	ID       string          `json:"id"`
+	Count    int64           `json:"count,string"`
+	Note     string          `json:"note,omitempty"`
+	Secret   string          `json:"-"`
+	Revision string          `json:"revision"` //shadows the embedded field
+	Raw      json.RawMessage `json:"raw"`
+	Data     []byte
+	hidden   int
+}
+
+type Owner struct {
+	OwnerName string
+}
+
+func TestRenameKeys(t *testing.T) {
+	res := taggedRes{
+		recordFields: recordFields{CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Revision: 3},
+		Owner:        &Owner{OwnerName: "ops"},
+		ID:           "a1",
+		Count:        7,
+		Revision:     "r3",
+		Raw:          json.RawMessage(`{"KeepMe":1}`),
+		Data:         []byte("hi"),
+	}
+	for _, v := range []interface{}{res, &res, taggedRes{Raw: json.RawMessage(`null`)}, []interface{}{res, nil}, map[string]taggedRes{"First": res}} {
+		//renaming nothing gives the encoding/json encoding
+		want, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to encode %T: %+v", v, err)
+		}
+		if got, err := json.Marshal(renameKeys(reflect.ValueOf(v), func(name string) string { return name })); err != nil || string(got) != string(want) {
+			t.Errorf("%T renamed %s, want %s, err %v", v, got, want, err)
+		}
+	}
+
+	got, _ := newKeyCaseEncoder(snakeCase, false)(map[string]interface{}{"Res": res})
+	if want := `{"Res":{"created_at":"2026-01-02T03:04:05Z","owner_name":"ops","id":"a1","count":"7","revision":"r3","raw":{"KeepMe":1},"data":"aGk="}}`; string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
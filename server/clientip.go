@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// ClientIP returns the IP of the client that sent the request being handled
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
+
+// parseTrustedProxies parses CIDRs or single IPs
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP %q", proxy)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Errorf("invalid CIDR %q", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func trusted(ip string, proxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the IP of the immediate peer
+func remoteIP(httpReq *http.Request) string {
+	host, _, err := net.SplitHostPort(httpReq.RemoteAddr)
+	if err != nil {
+		return httpReq.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns the IP of the client
+// X-Forwarded-For and X-Real-IP are only used when the peer is a trusted proxy,
+// then X-Forwarded-For is read from the right, skipping trusted proxies
+func clientIP(httpReq *http.Request, proxies []*net.IPNet) string {
+	peer := remoteIP(httpReq)
+	if !trusted(peer, proxies) {
+		return peer
+	}
+	if forwarded := httpReq.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break //cannot trust anything further left
+			}
+			if i == 0 || !trusted(hop, proxies) {
+				return hop
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(httpReq.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return peer
+}
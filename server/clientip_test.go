@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+func TestClientIP(t *testing.T) {
+	h := testHandler(t, Config{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}}, testMS{"ip": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		return ClientIP(ctx), nil
+	}}})
+	for name, test := range map[string]struct {
+		remoteAddr string
+		header     []string
+		want       string
+	}{
+		"direct":            {"203.0.113.5:1234", nil, "203.0.113.5"},
+		"trusted proxy":     {"10.1.1.1:1234", []string{"X-Forwarded-For", "203.0.113.5"}, "203.0.113.5"},
+		"proxy chain":       {"10.1.1.1:1234", []string{"X-Forwarded-For", "6.6.6.6, 203.0.113.5, 10.2.2.2"}, "203.0.113.5"},
+		"trusted single ip": {"192.168.1.1:1234", []string{"X-Real-IP", "203.0.113.5"}, "203.0.113.5"},
+		"untrusted spoof":   {"203.0.113.5:1234", []string{"X-Forwarded-For", "1.2.3.4"}, "203.0.113.5"},
+	} {
+		httpReq := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		httpReq.RemoteAddr = test.remoteAddr
+		for i := 0; i+1 < len(test.header); i += 2 {
+			httpReq.Header.Set(test.header[i], test.header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httpReq)
+		if w.Body.String() != `"`+test.want+`"` {
+			t.Errorf("%s got %d %s, expected %s", name, w.Code, w.Body, test.want)
+		}
+	}
+	if err := (Config{Addr: "localhost", TrustedProxies: []string{"10.0.0.0/33"}}).Validate(); err == nil {
+		t.Fatal("invalid CIDR accepted")
+	}
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// Decoder decodes the request body into reqPtr, a pointer to the operation request type
+// return io.EOF when the body is empty
+type Decoder func(httpReq *http.Request, reqPtr interface{}) error
+
+// DecodeJSON is the default Decoder, also used when the request has no Content-Type
+func DecodeJSON(httpReq *http.Request, reqPtr interface{}) error {
+	return json.NewDecoder(httpReq.Body).Decode(reqPtr)
+}
+
+// NewJSONDecoder returns a JSON Decoder with the json.Decoder options applied
+// unknown fields are reported as `json: unknown field "<name>"`
+func NewJSONDecoder(disallowUnknownFields bool, useNumber bool) Decoder {
+	return func(httpReq *http.Request, reqPtr interface{}) error {
+		decoder := json.NewDecoder(httpReq.Body)
+		if disallowUnknownFields {
+			decoder.DisallowUnknownFields()
+		}
+		if useNumber {
+			decoder.UseNumber()
+		}
+		return decoder.Decode(reqPtr)
+	}
+}
+
+// DecodeForm binds url-encoded form values into struct fields tagged with `form:"<name>"`
+// it returns io.EOF for a body without values, after checking required fields
+func DecodeForm(httpReq *http.Request, reqPtr interface{}) error {
+	if err := httpReq.ParseForm(); err != nil {
+		return err
+	}
+	if err := bindValues(reflect.ValueOf(reqPtr).Elem(), "form", func(name string) []string { return httpReq.PostForm[name] }); err != nil {
+		return err
+	}
+	if len(httpReq.PostForm) == 0 {
+		return io.EOF
+	}
+	return nil
+}
+
+// defaultMultipartMemory is the part of a multipart body kept in memory, the rest is stored in temporary files
+const defaultMultipartMemory = 32 << 20
+
+var fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
+
+// DecodeMultipart binds multipart/form-data text fields into struct fields tagged with `form:"<name>"`
+// and uploaded files into fields of type *multipart.FileHeader or []*multipart.FileHeader with the same tag
+func DecodeMultipart(httpReq *http.Request, reqPtr interface{}) error {
+	if err := httpReq.ParseMultipartForm(defaultMultipartMemory); err != nil {
+		return err
+	}
+	form := httpReq.MultipartForm
+	structValue := reflect.ValueOf(reqPtr).Elem()
+	if err := bindFiles(structValue, form.File); err != nil {
+		return err
+	}
+	return bindValues(structValue, "form", func(name string) []string { return form.Value[name] })
+}
+
+func bindFiles(structValue reflect.Value, files map[string][]*multipart.FileHeader) error {
+	if structValue.Kind() != reflect.Struct {
+		return nil
+	}
+	t := structValue.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("form"), ",")[0]
+		if f.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		switch {
+		case f.Type == fileHeaderType:
+			if len(files[name]) > 0 {
+				structValue.Field(i).Set(reflect.ValueOf(files[name][0]))
+			}
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem() == fileHeaderType:
+			structValue.Field(i).Set(reflect.ValueOf(files[name]))
+		}
+	}
+	return nil
+}
+
+// readBody reads the whole request body and replaces it so that it can be decoded afterwards
+func (s *server) readBody(httpReq *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(httpReq.Body)
+	if err != nil {
+		if isMaxBytesError(err) {
+			return nil, errors.Errorc(http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", s.config.MaxBodyBytes))
+		}
+		return nil, withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("failed to read body: %+v", err)))
+	}
+	httpReq.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// isMaxBytesError returns true if err is caused by a body exceeding MaxBodyBytes
+func isMaxBytesError(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return stderrors.As(err, &maxBytesErr)
+}
+
+// jsonBodyError translates JSON type and syntax errors into a 400 error
+// naming the field and expected type, or the offset of malformed JSON
+func jsonBodyError(err error) (error, bool) {
+	var typeErr *json.UnmarshalTypeError
+	if stderrors.As(err, &typeErr) {
+		expected := jsonTypeName(typeErr.Type)
+		if typeErr.Field == "" {
+			return withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid body: expected %s, got %s", expected, typeErr.Value))), true
+		}
+		return ValidationError{Fields: []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Param:   typeErr.Type.String(),
+			Message: fmt.Sprintf("%s must be %s, got %s", typeErr.Field, expected, typeErr.Value),
+		}}}, true
+	}
+	var syntaxErr *json.SyntaxError
+	if stderrors.As(err, &syntaxErr) {
+		return withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("malformed JSON at byte offset %d: %s", syntaxErr.Offset, syntaxErr.Error()))), true
+	}
+	if stderrors.Is(err, io.ErrUnexpectedEOF) {
+		return withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, "malformed JSON: unexpected end of body")), true
+	}
+	return nil, false
+}
+
+// jsonTypeName describes the JSON value expected for t
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return t.String()
+}
+
+func defaultDecoders(c Config) map[string]Decoder {
+	decodeJSON := DecodeJSON
+	if c.DisallowUnknownFields || c.UseNumber {
+		decodeJSON = NewJSONDecoder(c.DisallowUnknownFields, c.UseNumber)
+	}
+	return map[string]Decoder{
+		"application/json":                  decodeJSON,
+		"application/x-www-form-urlencoded": DecodeForm,
+		"multipart/form-data":               DecodeMultipart,
+		protobufMediaType:                   DecodeProtobuf,
+	}
+}
+
+// decoderFor selects the decoder from the request Content-Type
+func (s *server) decoderFor(httpReq *http.Request) (Decoder, error) {
+	contentType := httpReq.Header.Get("Content-Type")
+	if contentType == "" {
+		return s.decoders["application/json"], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errors.Errorc(http.StatusUnsupportedMediaType, fmt.Sprintf("invalid Content-Type %q", contentType))
+	}
+	decoder, ok := s.decoders[mediaType]
+	if !ok {
+		return nil, errors.Errorc(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %s", mediaType))
+	}
+	return decoder, nil
+}
+
+// Encoder serializes an operation result for the response body
+type Encoder func(res interface{}) ([]byte, error)
+
+func defaultEncoders(c Config) map[string]Encoder {
+	if c.PrettyJSON {
+		return map[string]Encoder{
+			"application/json": func(res interface{}) ([]byte, error) { return json.MarshalIndent(res, "", "  ") },
+			"application/xml":  func(res interface{}) ([]byte, error) { return xml.MarshalIndent(res, "", "  ") },
+			protobufMediaType:  EncodeProtobuf,
+		}
+	}
+	return map[string]Encoder{
+		"application/json": json.Marshal,
+		"application/xml":  xml.Marshal,
+		protobufMediaType:  EncodeProtobuf,
+	}
+}
+
+// encoderFor selects the response media type and encoder from the request Accept header
+// JSON is preferred when the client accepts any type
+func (s *server) encoderFor(httpReq *http.Request) (string, Encoder, error) {
+	accept := httpReq.Header.Get("Accept")
+	if accept == "" {
+		return "application/json", s.encoders["application/json"], nil
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue //earlier entries win ties
+		}
+		if candidate := s.matchEncoder(mediaType); candidate != "" {
+			best, bestQ = candidate, q
+		}
+	}
+	if best == "" {
+		return "", nil, errors.Errorc(http.StatusNotAcceptable, fmt.Sprintf("cannot produce any of %q", accept))
+	}
+	return best, s.encoders[best], nil
+}
+
+// matchEncoder returns the registered media type matching a possibly wildcard media type
+func (s *server) matchEncoder(mediaType string) string {
+	if _, ok := s.encoders[mediaType]; ok {
+		return mediaType
+	}
+	if mediaType == "*/*" {
+		return "application/json"
+	}
+	if prefix := strings.TrimSuffix(mediaType, "*"); prefix != mediaType {
+		if strings.HasPrefix("application/json", prefix) {
+			return "application/json"
+		}
+		mediaTypes := make([]string, 0, len(s.encoders))
+		for mt := range s.encoders {
+			mediaTypes = append(mediaTypes, mt)
+		}
+		sort.Strings(mediaTypes)
+		for _, mt := range mediaTypes {
+			if strings.HasPrefix(mt, prefix) {
+				return mt
+			}
+		}
+	}
+	return ""
+}
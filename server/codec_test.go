@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+type contactReq struct {
+	Name  string `json:"name" xml:"name" form:"name"`
+	Email string `json:"email" xml:"email" form:"email"`
+}
+
+func TestRequestDecoders(t *testing.T) {
+	h := testHandler(t, Config{Decoders: map[string]Decoder{
+		"text/plain": func(httpReq *http.Request, reqPtr interface{}) error {
+			body, err := io.ReadAll(httpReq.Body)
+			reqPtr.(*contactReq).Name = string(body)
+			return err
+		},
+	}}, testMS{"contact": testOper{reqType: reflect.TypeOf(contactReq{}), handle: echo}})
+	for contentType, body := range map[string]string{
+		"application/json":                  `{"name":"bob","email":"bob@example.com"}`,
+		"application/json; charset=utf-8":   `{"name":"bob","email":"bob@example.com"}`,
+		"":                                  `{"name":"bob","email":"bob@example.com"}`,
+		"application/x-www-form-urlencoded": "name=bob&email=bob%40example.com",
+	} {
+		w := do(h, http.MethodPost, "/contact", body, "Content-Type", contentType)
+		if w.Code != http.StatusOK || w.Body.String() != `{"name":"bob","email":"bob@example.com"}` {
+			t.Fatalf("%q got %d %s", contentType, w.Code, w.Body)
+		}
+	}
+	if w := do(h, http.MethodPost, "/contact", "bob", "Content-Type", "text/plain"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"bob"`) {
+		t.Fatalf("custom decoder got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/contact", "name,email", "Content-Type", "text/csv"); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unsupported type got %d", w.Code)
+	}
+}
+
+func TestResponseEncoders(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{"contact": testOper{handle: result(contactReq{Name: "bob", Email: "bob@example.com"})}})
+
+	w := do(h, http.MethodGet, "/contact", "", "Accept", "application/json")
+	var res contactReq
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Name != "bob" || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("json got %q %s: %v", w.Header().Get("Content-Type"), w.Body, err)
+	}
+	w = do(h, http.MethodGet, "/contact", "", "Accept", "application/xml")
+	if w.Header().Get("Content-Type") != "application/xml" || w.Body.String() != "<contactReq><name>bob</name><email>bob@example.com</email></contactReq>" {
+		t.Fatalf("xml got %q %s", w.Header().Get("Content-Type"), w.Body)
+	}
+	if w := do(h, http.MethodGet, "/contact", "", "Accept", "text/html, application/*;q=0.5"); w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("wildcard got %q", w.Header().Get("Content-Type"))
+	}
+	if w := do(h, http.MethodGet, "/contact", "", "Accept", "text/csv"); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("unsupported accept got %d", w.Code)
+	}
+}
+
+type numberReq struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+func TestJSONDecoderOptions(t *testing.T) {
+	var got interface{}
+	svc := testMS{"set": testOper{reqType: reflect.TypeOf(numberReq{}), handle: capture(&got)}}
+	body := `{"name":"a","value":12345678901234567890,"extra":true}`
+
+	if w := do(testHandler(t, Config{}, svc), http.MethodPost, "/set", body); w.Code != http.StatusNoContent {
+		t.Fatalf("lenient got %d %s", w.Code, w.Body)
+	}
+	if _, ok := got.(numberReq).Value.(float64); !ok {
+		t.Fatalf("lenient decoded %T", got.(numberReq).Value)
+	}
+
+	w := do(testHandler(t, Config{DisallowUnknownFields: true}, svc), http.MethodPost, "/set", body)
+	if w.Code != http.StatusBadRequest || !strings.Contains(errorBody(t, w.Body.Bytes()).Message, `"extra"`) {
+		t.Fatalf("strict got %d %s", w.Code, w.Body)
+	}
+
+	if w := do(testHandler(t, Config{UseNumber: true}, svc), http.MethodPost, "/set", body); w.Code != http.StatusNoContent {
+		t.Fatalf("use number got %d %s", w.Code, w.Body)
+	}
+	if n, ok := got.(numberReq).Value.(json.Number); !ok || n.String() != "12345678901234567890" {
+		t.Fatalf("use number decoded %T %v", got.(numberReq).Value, got.(numberReq).Value)
+	}
+}
+
+type uploadReq struct {
+	Title string                `form:"title"`
+	File  *multipart.FileHeader `form:"file"`
+}
+
+func TestMultipartUpload(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{"upload": testOper{reqType: reflect.TypeOf(uploadReq{}), handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		upload := req.(uploadReq)
+		if upload.File == nil {
+			return nil, errors.Errorc(http.StatusBadRequest, "missing file")
+		}
+		f, err := upload.File.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		content, err := io.ReadAll(f)
+		return map[string]string{"title": upload.Title, "filename": upload.File.Filename, "content": string(content)}, err
+	}}})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("file", "report.txt")
+	fw.Write([]byte("hello world"))
+	mw.Close()
+	w := do(h, http.MethodPost, "/upload", body.String(), "Content-Type", mw.FormDataContentType())
+	if w.Code != http.StatusOK || w.Body.String() != `{"content":"hello world","filename":"report.txt","title":"report"}` {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+
+	small := testHandler(t, Config{MaxBodyBytes: 64}, testMS{"upload": testOper{reqType: reflect.TypeOf(uploadReq{}), handle: echo}})
+	if w := do(small, http.MethodPost, "/upload", body.String(), "Content-Type", mw.FormDataContentType()); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized upload got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	svc := testMS{"contact": testOper{handle: result(contactReq{Name: "bob", Email: "bob@example.com"})}}
+	if w := do(testHandler(t, Config{PrettyJSON: true}, svc), http.MethodGet, "/contact", ""); w.Body.String() != "{\n  \"name\": \"bob\",\n  \"email\": \"bob@example.com\"\n}" {
+		t.Fatalf("pretty got %q", w.Body)
+	}
+	if w := do(testHandler(t, Config{}, svc), http.MethodGet, "/contact", ""); w.Body.String() != `{"name":"bob","email":"bob@example.com"}` {
+		t.Fatalf("compact got %q", w.Body)
+	}
+}
+
+type ageReq struct {
+	Age int `json:"age"`
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{"age": testOper{reqType: reflect.TypeOf(ageReq{}), handle: echo}})
+	for body, want := range map[string]string{
+		`{"age":"abc"}`: "age must be an integer, got string",
+		`{"age": 1,}`:   "malformed JSON at byte offset 11",
+		`{"age": 1`:     "malformed JSON: unexpected end of body",
+		`[1]`:           "invalid body: expected an object, got array",
+	} {
+		w := do(h, http.MethodPost, "/age", body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s got %d %s, expected %q", body, w.Code, w.Body, want)
+		}
+	}
+	if info := errorBody(t, do(h, http.MethodPost, "/age", `{"age":"abc"}`).Body.Bytes()); info.ErrorCode != ErrorCodeValidationFailed {
+		t.Fatalf("type mismatch error code %q", info.ErrorCode)
+	}
+}
+
+func TestGenericReqTypes(t *testing.T) {
+	var got interface{}
+	h := testHandler(t, Config{UseNumber: true}, testMS{
+		"map": testOper{reqType: reflect.TypeOf(map[string]interface{}{}), handle: capture(&got)},
+		"raw": testOper{reqType: reflect.TypeOf(json.RawMessage{}), handle: capture(&got)},
+	})
+
+	if w := do(h, http.MethodPost, "/map", `{"a":1,"b":{"c":[true]}}`); w.Code != http.StatusNoContent {
+		t.Fatalf("map got %d %s", w.Code, w.Body)
+	}
+	if want := map[string]interface{}{"a": json.Number("1"), "b": map[string]interface{}{"c": []interface{}{true}}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("map handler got %T %v", got, got)
+	}
+	//without a body the handler gets an empty map rather than nil
+	if do(h, http.MethodPost, "/map", ""); !reflect.DeepEqual(got, map[string]interface{}{}) {
+		t.Fatalf("map handler without body got %T %v", got, got)
+	}
+
+	body := "{ \"z\" : 1,\n \"a\":[ 2 ] }"
+	if w := do(h, http.MethodPost, "/raw", body); w.Code != http.StatusNoContent {
+		t.Fatalf("raw got %d %s", w.Code, w.Body)
+	}
+	if raw, ok := got.(json.RawMessage); !ok || string(raw) != body {
+		t.Fatalf("raw handler got %T %q", got, got)
+	}
+	if w := do(h, http.MethodPost, "/raw", "{bad"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid raw JSON got %d", w.Code)
+	}
+}
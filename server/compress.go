@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// defaultGzipMinBytes is used when Config.GzipMinBytes is not set
+const defaultGzipMinBytes = 1024
+
+// acceptsGzip returns true if the request Accept-Encoding allows gzip
+func acceptsGzip(httpReq *http.Request) bool {
+	for _, enc := range strings.Split(httpReq.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(enc, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if q := strings.TrimSpace(param); q == "q=0" || q == "q=0.0" || q == "q=0.00" || q == "q=0.000" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipBody returns body compressed, for a response with Content-Encoding gzip
+func gzipBody(body []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(body); err != nil {
+		gz.Close()
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// decompressBody wraps a request body sent with Content-Encoding gzip or deflate
+// in its decompressor, Config.MaxBodyBytes then limits the decompressed size
+func decompressBody(body io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	var reader io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(body)
+	case "deflate":
+		reader, err = zlib.NewReader(body)
+	default:
+		return nil, errors.Errorc(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported Content-Encoding %s", encoding))
+	}
+	if err != nil {
+		return nil, withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("malformed %s body: %v", encoding, err)))
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, body}, nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGzipResponse(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+	h := testHandler(t, Config{Gzip: true}, testMS{
+		"large": testOper{handle: result(large)},
+		"small": testOper{handle: result("small")},
+	})
+
+	w := do(h, http.MethodGet, "/large", "", "Accept-Encoding", "gzip")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %v", w.Code, w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := json.Unmarshal(body, &got); err != nil || got != large {
+		t.Fatalf("decompressed body %d bytes: %v", len(body), err)
+	}
+
+	if w := do(h, http.MethodGet, "/small", "", "Accept-Encoding", "gzip"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != `"small"` {
+		t.Fatalf("below threshold got %v %q", w.Header(), w.Body)
+	}
+	if w := do(h, http.MethodGet, "/large", ""); w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("compressed without Accept-Encoding")
+	}
+}
+
+func TestCompressedRequest(t *testing.T) {
+	h := testHandler(t, Config{MaxBodyBytes: 1024}, testMS{"contact": testOper{reqType: reflect.TypeOf(contactReq{}), handle: echo}})
+	compress := func(w io.WriteCloser, body string) {
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Fatalf("failed to compress: %+v", err)
+		}
+		w.Close()
+	}
+	send := func(body []byte, encoding string) *httptest.ResponseRecorder {
+		httpReq := httptest.NewRequest(http.MethodPost, "/contact", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Encoding", encoding)
+		httpRes := httptest.NewRecorder()
+		h.ServeHTTP(httpRes, httpReq)
+		return httpRes
+	}
+
+	var gzipped, deflated bytes.Buffer
+	compress(gzip.NewWriter(&gzipped), `{"name":"bob"}`)
+	compress(zlib.NewWriter(&deflated), `{"name":"bob"}`)
+	for encoding, body := range map[string][]byte{"gzip": gzipped.Bytes(), "deflate": deflated.Bytes()} {
+		if w := send(body, encoding); w.Code != http.StatusOK || w.Body.String() != `{"name":"bob","email":""}` {
+			t.Errorf("%s body got %d %s", encoding, w.Code, w.Body)
+		}
+	}
+
+	//the limit applies to the decompressed size
+	var bomb bytes.Buffer
+	compress(gzip.NewWriter(&bomb), `{"name":"`+strings.Repeat("a", 100000)+`"}`)
+	if bomb.Len() > 1024 {
+		t.Fatalf("compressed bomb has %d bytes", bomb.Len())
+	}
+	if w := send(bomb.Bytes(), "gzip"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("bomb got %d %s", w.Code, w.Body)
+	}
+	if w := send([]byte(`{"name":"bob"}`), "gzip"); w.Code != http.StatusBadRequest || errorBody(t, w.Body.Bytes()).ErrorCode != ErrorCodeInvalidBody {
+		t.Fatalf("malformed body got %d %s", w.Code, w.Body)
+	}
+	if w := send([]byte(`{"name":"bob"}`), "br"); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unsupported encoding got %d %s", w.Code, w.Body)
+	}
+}
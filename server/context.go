@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+// requestContext is the ms.Context passed to operation handlers
+// it adds request scoped values to the context created by the micro-service
+type requestContext struct {
+	ms.Context
+	ctx context.Context
+}
+
+func newRequestContext(msCtx ms.Context, ctx context.Context) *requestContext {
+	return &requestContext{Context: msCtx, ctx: ctx}
+}
+
+func (c *requestContext) with(key, value interface{}) {
+	c.ctx = context.WithValue(c.ctx, key, value)
+}
+
+func (c *requestContext) Deadline() (time.Time, bool) { return c.ctx.Deadline() }
+func (c *requestContext) Done() <-chan struct{}       { return c.ctx.Done() }
+func (c *requestContext) Err() error                  { return c.ctx.Err() }
+
+func (c *requestContext) Value(key interface{}) interface{} {
+	if v := c.ctx.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// detach returns ctx without its cancellation, for a handler shared by several requests,
+// keeping the values and the deadline of ctx
+func detach(ctx ms.Context) (ms.Context, context.CancelFunc) {
+	detached, cancel := context.Background(), context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok {
+		detached, cancel = context.WithDeadline(detached, deadline)
+	}
+	return newRequestContext(ctx, detached), cancel
+}
+
+// ContextBuilder enriches the context of an operation from the HTTP request,
+// e.g. with a tenant or locale, returning an error to fail the request
+type ContextBuilder func(ctx ms.Context, httpReq *http.Request) (ms.Context, error)
+
+// WithValue returns a copy of ctx with the value for key, for use in a ContextBuilder
+func WithValue(ctx ms.Context, key, value interface{}) ms.Context {
+	return &requestContext{Context: ctx, ctx: context.WithValue(ctx, key, value)}
+}
+
+type contextKey string
+
+const (
+	clientCertSubjectKey contextKey = "clientCertSubject"
+	requestIDKey         contextKey = "requestID"
+	causationIDKey       contextKey = "causationID"
+	principalKey         contextKey = "principal"
+	clientIPKey          contextKey = "clientIP"
+	ifMatchKey           contextKey = "ifMatch"
+	ifUnmodifiedSinceKey contextKey = "ifUnmodifiedSince"
+	localeKey            contextKey = "locale"
+	responseHeadersKey   contextKey = "responseHeaders"
+)
+
+// ClientCertSubject returns the subject of the verified TLS client certificate
+func ClientCertSubject(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(clientCertSubjectKey).(string)
+	return subject, ok
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+type tenantKey struct{}
+
+func TestContextBuilder(t *testing.T) {
+	h := testHandler(t, Config{ContextBuilder: func(ctx ms.Context, httpReq *http.Request) (ms.Context, error) {
+		tenant := httpReq.Header.Get("X-Tenant")
+		if tenant == "" {
+			return nil, errors.Errorc(http.StatusBadRequest, "missing X-Tenant")
+		}
+		return WithValue(ctx, tenantKey{}, tenant), nil
+	}}, testMS{"whoami": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant + "/" + RequestID(ctx), nil
+	}}})
+	if w := do(h, http.MethodGet, "/whoami", "", "X-Tenant", "acme", RequestIDHeader, "req-1"); w.Code != http.StatusOK || w.Body.String() != `"acme/req-1"` {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodGet, "/whoami", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("builder error got %d %s", w.Code, w.Body)
+	}
+}
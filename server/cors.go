@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// CORSConfig enables Cross-Origin Resource Sharing headers
+type CORSConfig struct {
+	// AllowedOrigins lists origins allowed to call the server, "*" allows any origin
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, HEAD and POST
+	AllowedMethods []string
+	// AllowedHeaders lists request headers allowed in preflight, "*" allows any header
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge in seconds that a preflight result may be cached, 0 omits the header
+	MaxAge int
+}
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+func (c CORSConfig) Validate() error {
+	if len(c.AllowedOrigins) == 0 {
+		return errors.Errorf("missing allowedOrigins")
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "" {
+			return errors.Errorf("empty origin in allowedOrigins")
+		}
+		if origin == "*" && c.AllowCredentials {
+			return errors.Errorf("allowedOrigins \"*\" cannot be used with allowCredentials")
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.Errorf("negative maxAge:%d", c.MaxAge)
+	}
+	return nil
+}
+
+// allowedOrigin returns the value for Access-Control-Allow-Origin, or "" if origin is not allowed
+func (c CORSConfig) allowedOrigin(origin string) string {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// handle sets the CORS response headers
+// it returns true when the request was a preflight that has been answered
+func (c CORSConfig) handle(httpRes http.ResponseWriter, httpReq *http.Request) (bool, error) {
+	origin := httpReq.Header.Get("Origin")
+	if origin == "" {
+		return false, nil //not a CORS request
+	}
+	h := httpRes.Header()
+	h.Add("Vary", "Origin")
+	preflight := httpReq.Method == http.MethodOptions && httpReq.Header.Get("Access-Control-Request-Method") != ""
+	allowedOrigin := c.allowedOrigin(origin)
+	if allowedOrigin == "" {
+		if preflight {
+			return true, errors.Errorc(http.StatusForbidden, fmt.Sprintf("origin %s not allowed", origin))
+		}
+		return false, nil //no CORS headers, so the browser will block the response
+	}
+	h.Set("Access-Control-Allow-Origin", allowedOrigin)
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		return false, nil
+	}
+
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	if reqMethod := httpReq.Header.Get("Access-Control-Request-Method"); !methodAllowed(reqMethod, methods) {
+		return true, errors.Errorc(http.StatusForbidden, fmt.Sprintf("method %s not allowed", reqMethod))
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	for _, header := range c.AllowedHeaders {
+		if header == "*" {
+			if reqHeaders := httpReq.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			break
+		}
+	}
+	if h.Get("Access-Control-Allow-Headers") == "" && len(c.AllowedHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+	}
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+	}
+	httpRes.WriteHeader(http.StatusNoContent)
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	h := testHandler(t, Config{CORS: &CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         600,
+	}}, testMS{"get": testOper{handle: result("ok")}})
+
+	w := do(h, http.MethodOptions, "/get", "", "Origin", "https://app.example.com", "Access-Control-Request-Method", http.MethodPost)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight got %d", w.Code)
+	}
+	for name, value := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Content-Type",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s got %q, expected %q", name, got, value)
+		}
+	}
+
+	w = do(h, http.MethodGet, "/get", "", "Origin", "https://app.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("actual request got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	h := testHandler(t, Config{CORS: &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}}, testMS{"get": testOper{handle: result("ok")}})
+	if w := do(h, http.MethodOptions, "/get", "", "Origin", "https://evil.example.com", "Access-Control-Request-Method", http.MethodGet); w.Code != http.StatusForbidden {
+		t.Fatalf("preflight got %d", w.Code)
+	}
+	w := do(h, http.MethodGet, "/get", "", "Origin", "https://evil.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	h := testHandler(t, Config{CORS: &CORSConfig{AllowedOrigins: []string{"*"}}}, testMS{"get": testOper{handle: result("ok")}})
+	w := do(h, http.MethodGet, "/get", "", "Origin", "https://any.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("got %q", got)
+	}
+	if err := (CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Validate(); err == nil {
+		t.Fatal("wildcard origin with credentials accepted")
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// dedupHeaders are part of the deduplication key, as they can change the response
+var dedupHeaders = []string{"Authorization", "Cookie", "Content-Type", "Accept", "Accept-Encoding", "Accept-Version"}
+
+// dedupKey identifies identical requests to an operation by method, URL,
+// headers that could change the response, headers bound to request fields,
+// the principal of the Authenticator and the body
+func dedupKey(operName string, principal interface{}, reqType reflect.Type, httpReq *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{operName, httpReq.Method, httpReq.URL.RequestURI(), fmt.Sprintf("%+v", principal)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	for _, names := range [][]string{dedupHeaders, tagNames(reqType, "header")} {
+		for _, name := range names {
+			for _, value := range httpReq.Header.Values(name) {
+				h.Write([]byte(name + ":" + value))
+				h.Write([]byte{0})
+			}
+			h.Write([]byte{0})
+		}
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// tagNames returns the names in tag tagName of the fields bound by bindValues
+func tagNames(t reflect.Type, tagName string) []string {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue //unexported
+		}
+		name := strings.Split(f.Tag.Get(tagName), ",")[0]
+		if name == "" || name == "-" {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				names = append(names, tagNames(f.Type, tagName)...)
+			}
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// dedupMethod returns true for methods of requests that are collapsed
+func dedupMethod(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+// dedupResult is shared with identical concurrent requests,
+// including the response headers staged by the handler
+type dedupResult struct {
+	res    interface{}
+	staged *stagedHeaders
+}
+
+// isShareable returns false for results that are consumed when written
+func isShareable(res interface{}) bool {
+	if _, ok := asDownload(res); ok {
+		return false
+	}
+	if _, ok := res.(Streamer); ok {
+		return false
+	}
+	return res == nil || reflect.TypeOf(res).Kind() != reflect.Chan
+}
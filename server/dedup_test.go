@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+// concurrently sends the requests, each with its header name/value pairs,
+// at the same time and returns the responses in order
+func concurrently(h http.Handler, method, target, body string, headers ...[]string) []int {
+	codes := make([]int, len(headers))
+	var wg sync.WaitGroup
+	for i, header := range headers {
+		wg.Add(1)
+		go func(i int, header []string) {
+			defer wg.Done()
+			codes[i] = do(h, method, target, body, header...).Code
+		}(i, header)
+	}
+	wg.Wait()
+	return codes
+}
+
+func TestDedup(t *testing.T) {
+	var calls int32
+	h := testHandler(t, Config{
+		DedupRequests: true,
+		Authenticator: func(httpReq *http.Request) (interface{}, error) { return httpReq.Header.Get("X-Api-Key"), nil },
+	}, testMS{
+		"create": testOper{reqType: reflect.TypeOf(tenantReq{}), handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			if req.(tenantReq).Name == "fail" {
+				return nil, errors.Errorf("failed")
+			}
+			return req, nil
+		}},
+	})
+	for _, tc := range []struct {
+		name    string
+		body    string
+		headers [][]string
+		calls   int32
+		code    int
+	}{
+		{"identical", `{"name":"a"}`, [][]string{{"X-Tenant-ID", "t1"}, {"X-Tenant-ID", "t1"}, {"X-Tenant-ID", "t1"}}, 1, http.StatusOK},
+		{"shared error", `{"name":"fail"}`, [][]string{{"X-Tenant-ID", "t1"}, {"X-Tenant-ID", "t1"}}, 1, http.StatusInternalServerError},
+		{"bound header", `{"name":"a"}`, [][]string{{"X-Tenant-ID", "t1"}, {"X-Tenant-ID", "t2"}, {"X-Tenant-ID", "t1", "X-Api-Version", "2"}}, 3, http.StatusOK},
+		{"principal", `{"name":"a"}`, [][]string{{"X-Tenant-ID", "t1", "X-Api-Key", "alice"}, {"X-Tenant-ID", "t1", "X-Api-Key", "bob"}}, 2, http.StatusOK},
+	} {
+		atomic.StoreInt32(&calls, 0)
+		codes := concurrently(h, http.MethodPost, "/create", tc.body, tc.headers...)
+		if got := atomic.LoadInt32(&calls); got != tc.calls {
+			t.Errorf("%s: handler called %d times, expected %d", tc.name, got, tc.calls)
+		}
+		for i, code := range codes {
+			if code != tc.code {
+				t.Errorf("%s: request %d got %d, expected %d", tc.name, i, code, tc.code)
+			}
+		}
+	}
+	//GET is not deduplicated
+	atomic.StoreInt32(&calls, 0)
+	concurrently(h, http.MethodGet, "/create?name=a", "", []string{"X-Tenant-ID", "t1"}, []string{"X-Tenant-ID", "t1"})
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("GET handler called %d times", got)
+	}
+}
+
+func TestDedupFirstClientClosed(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := testHandler(t, Config{DedupRequests: true}, testMS{
+		"create": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			close(started)
+			select {
+			case <-release:
+				return "created", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}},
+	})
+	firstCtx, cancel := context.WithCancel(context.Background())
+	first := make(chan struct{})
+	go func() {
+		defer close(first)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/create", nil).WithContext(firstCtx))
+	}()
+	<-started
+	second := make(chan *httptest.ResponseRecorder)
+	go func() { second <- do(h, http.MethodPost, "/create", "") }()
+	time.Sleep(20 * time.Millisecond) //let the second request join the first
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if w := <-second; w.Code != http.StatusOK || w.Body.String() != `"created"` {
+		t.Errorf("second request got %d %s", w.Code, w.Body)
+	}
+	<-first
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// Download is a result written as a file instead of being encoded,
+// e.g. return &server.Download{Body: csv, ContentType: "text/csv", Filename: "export.csv"}
+type Download struct {
+	Body        io.Reader //closed after writing when it is an io.Closer
+	ContentType string    //defaults to "application/octet-stream"
+	Filename    string    //suggested name for Content-Disposition: attachment
+	Size        int64     //set as Content-Length when positive
+}
+
+// asDownload returns the download of a *Download or Download result
+func asDownload(res interface{}) (*Download, bool) {
+	switch d := res.(type) {
+	case *Download:
+		return d, d != nil
+	case Download:
+		return &d, true
+	}
+	return nil, false
+}
+
+// writeDownload writes the headers and copies the body, unless the request is HEAD
+func writeDownload(httpRes http.ResponseWriter, httpReq *http.Request, status int, download *Download) error {
+	if closer, ok := download.Body.(io.Closer); ok {
+		defer closer.Close()
+	}
+	contentType := download.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	httpRes.Header().Set("Content-Type", contentType)
+	if download.Filename != "" {
+		//escapes quotes and uses RFC 2231 encoding for non-ASCII names
+		httpRes.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": download.Filename}))
+	}
+	if download.Size > 0 {
+		httpRes.Header().Set("Content-Length", strconv.FormatInt(download.Size, 10))
+	}
+	httpRes.WriteHeader(status)
+	if httpReq.Method == http.MethodHead || download.Body == nil {
+		return nil
+	}
+	_, err := io.Copy(httpRes, download.Body)
+	return err
+}
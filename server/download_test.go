@@ -0,0 +1,48 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+// closeRecorder records whether the download body was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestDownload(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("id,name\n1,bob\n")}
+	h := testHandler(t, Config{}, testMS{
+		"export": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return &Download{Body: body, ContentType: "text/csv", Filename: "users 2024.csv", Size: 14}, nil
+		}},
+		"raw": testOper{handle: result(Download{Body: strings.NewReader("bytes")})},
+	})
+	w := do(h, http.MethodGet, "/export", "", "Accept", "application/json")
+	if w.Code != http.StatusOK || w.Body.String() != "id,name\n1,bob\n" || !body.closed {
+		t.Fatalf("got %d %q closed:%v", w.Code, w.Body, body.closed)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":        "text/csv",
+		"Content-Disposition": `attachment; filename="users 2024.csv"`,
+		"Content-Length":      "14",
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s: %q, expected %q", name, got, want)
+		}
+	}
+	w = do(h, http.MethodGet, "/raw", "")
+	if w.Body.String() != "bytes" || w.Header().Get("Content-Type") != "application/octet-stream" || w.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("raw download got %q %v", w.Body, w.Header())
+	}
+}
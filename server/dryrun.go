@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// DryRunHeader asks to decode and validate the request without calling the operation handler
+const DryRunHeader = "X-Dry-Run"
+
+// DryRunResult is the response body of a valid dry run
+// an invalid request gets the usual error response
+type DryRunResult struct {
+	DryRun    bool   `json:"dryRun"`
+	Operation string `json:"operation"`
+	Valid     bool   `json:"valid"`
+}
+
+// isDryRun is true when the request has "X-Dry-Run: true" or,
+// when param is set, a true query parameter like "?dryRun=true"
+func isDryRun(httpReq *http.Request, param string) bool {
+	if dryRun, _ := strconv.ParseBool(httpReq.Header.Get(DryRunHeader)); dryRun {
+		return true
+	}
+	if param == "" {
+		return false
+	}
+	dryRun, _ := strconv.ParseBool(httpReq.URL.Query().Get(param))
+	return dryRun
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+func TestDryRun(t *testing.T) {
+	called := false
+	var sink bytes.Buffer
+	h, rec := loggedHandler(t, Config{DryRunParam: "dryRun", AccessLog: true, AccessLogWriter: &sink}, testMS{
+		"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: func(ms.Context, interface{}) (interface{}, error) {
+			called = true
+			return "created", nil
+		}},
+	})
+	w := do(h, http.MethodPost, "/create", `{"name":"a"}`, DryRunHeader, "true")
+	var res DryRunResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || w.Code != http.StatusOK || called {
+		t.Fatalf("valid dry run got %d %s, handler called: %v", w.Code, w.Body, called)
+	}
+	if res != (DryRunResult{DryRun: true, Operation: "create", Valid: true}) {
+		t.Fatalf("dry run result %+v", res)
+	}
+	if !rec.contains("dry run of create") {
+		t.Fatalf("dry run not logged: %q", rec.lines)
+	}
+	var entry accessLogEntry
+	if err := json.Unmarshal(bytes.TrimSpace(sink.Bytes()), &entry); err != nil || !entry.DryRun {
+		t.Fatalf("access log %s: %v", sink.Bytes(), err)
+	}
+	if w := do(h, http.MethodPost, "/create?dryRun=1", `{}`); w.Code != http.StatusBadRequest || called {
+		t.Fatalf("invalid dry run got %d, handler called: %v", w.Code, called)
+	}
+	if w := do(h, http.MethodPost, "/create", `{"name":"a"}`, DryRunHeader, "false"); w.Code != http.StatusOK || !called {
+		t.Fatalf("normal request got %d, handler called: %v", w.Code, called)
+	}
+}
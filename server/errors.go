@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-msvc/ms"
+)
+
+// ErrorWriter writes the response for a failed request
+// code is the HTTP status code resolved from err
+type ErrorWriter func(httpRes http.ResponseWriter, httpReq *http.Request, code int, err error)
+
+// ErrorMapper returns the HTTP status code for err,
+// or 0 to fall back to the Code() of the error
+type ErrorMapper func(err error) int
+
+// ErrorMapperOper is optionally implemented by an operation
+// to map its own errors before Config.ErrorMapper is consulted
+type ErrorMapperOper interface {
+	MapError(err error) int
+}
+
+// operErrorMapper returns the MapError of an ErrorMapperOper, else nil
+func operErrorMapper(oper ms.Oper) ErrorMapper {
+	if mapperOper, ok := oper.(ErrorMapperOper); ok {
+		return mapperOper.MapError
+	}
+	return nil
+}
+
+// errorCode resolves the HTTP status code of err by trying the mappers on
+// err and every error it wraps, then the first Code() in the chain, defaulting to 500
+func errorCode(err error, mappers ...ErrorMapper) int {
+	for _, mapper := range mappers {
+		if mapper == nil {
+			continue
+		}
+		for e := err; e != nil; e = parentError(e) {
+			if code := mapper(e); code != 0 && http.StatusText(code) != "" {
+				return code
+			}
+		}
+	}
+	for e := err; e != nil; e = parentError(e) {
+		if c, ok := e.(codeError); ok && http.StatusText(c.Code()) != "" {
+			return c.Code()
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// parentError returns the error wrapped by err, if any
+func parentError(err error) error {
+	if e, ok := err.(interface{ Parent() error }); ok {
+		return e.Parent()
+	}
+	return stderrors.Unwrap(err)
+}
+
+// codeError is implemented by errors with an HTTP status code, such as errors.IError
+type codeError interface {
+	error
+	Code() int
+}
+
+// ErrorCoder is optionally implemented by an error, e.g. an errors.IError, to set
+// the machine-readable errorCode of the error body
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+// error codes of the errors detected by the server, other errors without an
+// ErrorCoder get a code derived from the HTTP status, e.g. "NOT_FOUND"
+const (
+	ErrorCodeValidationFailed = "VALIDATION_FAILED"
+	ErrorCodeInvalidBody      = "INVALID_BODY"
+	ErrorCodeBodyRequired     = "BODY_REQUIRED"
+	ErrorCodeUnexpectedBody   = "UNEXPECTED_BODY"
+	ErrorCodeInvalidParameter = "INVALID_PARAMETER"
+	ErrorCodeUnknownOperation = "UNKNOWN_OPERATION"
+	ErrorCodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+)
+
+// ErrorCodeOf returns the ErrorCode() of err or the errors it wraps,
+// else the HTTP status text in upper snake case, e.g. "TOO_MANY_REQUESTS"
+// for use in a custom ErrorWriter
+func ErrorCodeOf(err error, status int) string {
+	for e := err; e != nil; e = parentError(e) {
+		if c, ok := e.(ErrorCoder); ok && c.ErrorCode() != "" {
+			return c.ErrorCode()
+		}
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		text = http.StatusText(http.StatusInternalServerError)
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// errorDetails returns the details of the first error in the chain that has them
+func errorDetails(err error) interface{} {
+	for e := err; e != nil; e = parentError(e) {
+		if detailer, ok := e.(ErrorDetailer); ok {
+			return detailer.Details()
+		}
+	}
+	return nil
+}
+
+// codedError sets the error code of an error created by the server
+type codedError struct {
+	error
+	errorCode string
+}
+
+func withErrorCode(errorCode string, err error) error {
+	return codedError{error: err, errorCode: errorCode}
+}
+
+func (e codedError) ErrorCode() string { return e.errorCode }
+
+func (e codedError) Unwrap() error { return e.error }
+
+// internalError replaces a server error for the client, see Config.ExposeErrorDetails
+type internalError struct {
+	code      int
+	errorCode string
+}
+
+func (e internalError) Error() string { return strings.ToLower(http.StatusText(e.code)) }
+
+func (e internalError) Code() int { return e.code }
+
+func (e internalError) ErrorCode() string { return e.errorCode }
+
+// exposedError returns err as the client may see it, without Config.ExposeErrorDetails
+// errors with status >= 500 only keep their status text and error code
+func (s *server) exposedError(err error, code int) error {
+	if err == nil || code < 500 || s.config.ExposeErrorDetails {
+		return err
+	}
+	return internalError{code: code, errorCode: ErrorCodeOf(err, code)}
+}
+
+// FieldError describes an invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError is a 400 Bad Request error with the invalid fields as details
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return "invalid request: " + strings.Join(messages, ", ")
+}
+
+func (e ValidationError) Code() int {
+	return http.StatusBadRequest
+}
+
+func (e ValidationError) ErrorCode() string {
+	return ErrorCodeValidationFailed
+}
+
+func (e ValidationError) Details() interface{} {
+	return e.Fields
+}
+
+// ErrorDetailer is optionally implemented by an error, or an error it wraps, to add details to the error body
+type ErrorDetailer interface {
+	Details() interface{}
+}
+
+// ErrorBody is the default JSON error envelope
+type ErrorBody struct {
+	Error ErrorInfo `json:"error"`
+}
+
+type ErrorInfo struct {
+	Code      int         `json:"code"`
+	ErrorCode string      `json:"errorCode"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// WriteJSONError is the default ErrorWriter
+// it writes an ErrorBody with Content-Type application/json
+func WriteJSONError(httpRes http.ResponseWriter, httpReq *http.Request, code int, err error) {
+	body := ErrorBody{
+		Error: ErrorInfo{
+			Code:      code,
+			ErrorCode: ErrorCodeOf(err, code),
+			Message:   err.Error(),
+		},
+	}
+	body.Error.Details = errorDetails(err)
+	jsonBody, jsonErr := json.Marshal(body)
+	if jsonErr != nil {
+		log.Errorf("failed to encode error body: %+v", jsonErr)
+		jsonBody, _ = json.Marshal(ErrorBody{Error: ErrorInfo{Code: code, ErrorCode: ErrorCodeOf(err, code), Message: err.Error()}}) //details could not be encoded
+	}
+	jsonBody = append(jsonBody, '\n')
+	httpRes.Header().Set("Content-Type", "application/json")
+	httpRes.Header().Set("X-Content-Type-Options", "nosniff")
+	httpRes.Header().Set("Content-Length", strconv.Itoa(len(jsonBody)))
+	httpRes.WriteHeader(code)
+	if _, err := httpRes.Write(jsonBody); err != nil {
+		log.Errorf("failed to write error body: %+v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+// errorBody decodes the default error envelope of a response
+func errorBody(t *testing.T, body []byte) ErrorInfo {
+	t.Helper()
+	var errBody ErrorBody
+	if err := json.Unmarshal(body, &errBody); err != nil {
+		t.Fatalf("invalid error body %s: %+v", body, err)
+	}
+	return errBody.Error
+}
+
+func TestPanicRecovery(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"panic": testOper{handle: func(ms.Context, interface{}) (interface{}, error) { panic("boom") }},
+		"fail": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorc(http.StatusConflict, "taken")
+		}},
+	})
+	w := do(h, http.MethodGet, "/panic", "")
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if info := errorBody(t, w.Body.Bytes()); info.Code != http.StatusInternalServerError {
+		t.Fatalf("got %+v", info)
+	}
+	if w := do(h, http.MethodGet, "/fail", ""); w.Code != http.StatusConflict {
+		t.Fatalf("handler error got %d", w.Code)
+	}
+}
+
+type validatedReq struct {
+	Name string `json:"name"`
+}
+
+func (r validatedReq) Validate() error {
+	if r.Name == "" {
+		return errors.Error("missing name")
+	}
+	return nil
+}
+
+func TestJSONErrorBody(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo},
+		"fail":   testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, errors.Error("db down") }},
+	})
+	for target, code := range map[string]int{"/create": http.StatusBadRequest, "/fail": http.StatusInternalServerError} {
+		w := do(h, http.MethodPost, target, `{}`)
+		if w.Code != code || w.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("%s got %d %q", target, w.Code, w.Header().Get("Content-Type"))
+		}
+		if info := errorBody(t, w.Body.Bytes()); info.Code != code || info.Message == "" {
+			t.Fatalf("%s got %+v", target, info)
+		}
+	}
+}
+
+func TestCustomErrorWriter(t *testing.T) {
+	writer := func(httpRes http.ResponseWriter, httpReq *http.Request, code int, err error) {
+		httpRes.Header().Set("Content-Type", "application/vnd.error+json")
+		httpRes.WriteHeader(code)
+		json.NewEncoder(httpRes).Encode(map[string]interface{}{"status": code, "reason": err.Error()})
+	}
+	h := testHandler(t, Config{ErrorWriter: writer}, testMS{})
+	w := do(h, http.MethodGet, "/missing", "")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/vnd.error+json" {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+}
+
+var (
+	errNoSuchUser = errors.Error("no such user")
+	errLocked     = errors.Error("locked")
+)
+
+// mappedOper maps its own errors
+type mappedOper struct{ testOper }
+
+func (o mappedOper) MapError(err error) int {
+	if err == errLocked {
+		return http.StatusLocked
+	}
+	return 0
+}
+
+func TestErrorMapper(t *testing.T) {
+	fail := func(err error) func(ms.Context, interface{}) (interface{}, error) {
+		return func(ms.Context, interface{}) (interface{}, error) { return nil, err }
+	}
+	h := testHandler(t, Config{ErrorMapper: func(err error) int {
+		switch err {
+		case errNoSuchUser:
+			return http.StatusNotFound
+		case errLocked:
+			return http.StatusConflict
+		}
+		return 0
+	}}, testMS{
+		"missing": testOper{handle: fail(errNoSuchUser)},
+		"wrapped": testOper{handle: fail(errors.Wrapf(errNoSuchUser, "failed to get user"))},
+		"locked":  mappedOper{testOper{handle: fail(errLocked)}},
+		"global":  testOper{handle: fail(errLocked)},
+		"coded":   testOper{handle: fail(errors.Errorc(http.StatusTooManyRequests, "slow down"))},
+		"other":   testOper{handle: fail(errors.Error("db down"))},
+	})
+	for operName, code := range map[string]int{
+		"missing": http.StatusNotFound,
+		"wrapped": http.StatusNotFound,
+		"locked":  http.StatusLocked,
+		"global":  http.StatusConflict,
+		"coded":   http.StatusTooManyRequests,
+		"other":   http.StatusInternalServerError,
+	} {
+		if w := do(h, http.MethodGet, "/"+operName, ""); w.Code != code {
+			t.Errorf("%s got %d, expected %d", operName, w.Code, code)
+		}
+	}
+}
+
+// codedErr supplies its own error code
+type codedErr struct{}
+
+func (codedErr) Error() string     { return "quota exceeded" }
+func (codedErr) ErrorCode() string { return "QUOTA_EXCEEDED" }
+
+func TestErrorCodes(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"getUser": methodsOper{testOper{handle: result("user")}, []string{http.MethodGet}},
+		"create":  bodyOper{testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo}},
+		"list":    testOper{reqType: reflect.TypeOf(queryReq{}), handle: echo},
+		"update":  testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, ErrPreconditionFailed }},
+		"upload": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Wrapf(codedErr{}, "upload failed")
+		}},
+		"conflict": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorc(http.StatusConflict, "exists")
+		}},
+	})
+	for _, tc := range []struct{ method, target, body, want string }{
+		{http.MethodGet, "/missing", "", ErrorCodeUnknownOperation},
+		{http.MethodPost, "/getUser", "", ErrorCodeMethodNotAllowed},
+		{http.MethodPost, "/create", "", ErrorCodeBodyRequired},
+		{http.MethodPost, "/create", "{", ErrorCodeInvalidBody},
+		{http.MethodPost, "/create", `{}`, ErrorCodeValidationFailed},
+		{http.MethodGet, "/list?id=x", "", ErrorCodeInvalidParameter},
+		{http.MethodPut, "/update", "", "PRECONDITION_FAILED"},
+		{http.MethodPost, "/upload", "", "QUOTA_EXCEEDED"},
+		{http.MethodPost, "/conflict", "", "CONFLICT"},
+	} {
+		w := do(h, tc.method, tc.target, tc.body)
+		if info := errorBody(t, w.Body.Bytes()); info.ErrorCode != tc.want || info.Code != w.Code {
+			t.Errorf("%s %s got %d %s, expected %s", tc.method, tc.target, w.Code, w.Body, tc.want)
+		}
+	}
+}
+
+func TestOnServerError(t *testing.T) {
+	type alert struct {
+		operName string
+		code     int
+	}
+	var alerts []alert
+	h := testHandler(t, Config{OnServerError: func(httpReq *http.Request, operName string, err error, code int) {
+		if err == nil {
+			t.Errorf("%s alerted without an error", operName)
+		}
+		alerts = append(alerts, alert{operName, code})
+	}}, testMS{
+		"panic": testOper{handle: func(ms.Context, interface{}) (interface{}, error) { panic("boom") }},
+		"fail":  testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, errors.Error("db down") }},
+		"conflict": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorc(http.StatusConflict, "taken")
+		}},
+		"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo},
+	})
+	for _, target := range []string{"/panic", "/fail", "/conflict", "/create"} {
+		do(h, http.MethodPost, target, `{}`)
+	}
+	if want := []alert{{"panic", http.StatusInternalServerError}, {"fail", http.StatusInternalServerError}}; !reflect.DeepEqual(alerts, want) {
+		t.Fatalf("got alerts %+v, want %+v", alerts, want)
+	}
+}
+
+// quotaErr has details for the error body
+type quotaErr struct{}
+
+func (quotaErr) Error() string        { return "quota exceeded" }
+func (quotaErr) Code() int            { return http.StatusTooManyRequests }
+func (quotaErr) Details() interface{} { return map[string]int{"limit": 100} }
+
+func TestErrorDetails(t *testing.T) {
+	svc := testMS{"upload": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+		return nil, errors.Wrapf(quotaErr{}, "upload failed")
+	}}}
+	want := map[string]interface{}{"limit": float64(100)}
+	w := do(testHandler(t, Config{}, svc), http.MethodPost, "/upload", "")
+	if info := errorBody(t, w.Body.Bytes()); w.Code != http.StatusTooManyRequests || !reflect.DeepEqual(info.Details, want) {
+		t.Fatalf("got %d %+v", w.Code, info)
+	}
+	w = do(testHandler(t, Config{ProblemDetails: true}, svc), http.MethodPost, "/upload", "")
+	var problem ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || !reflect.DeepEqual(problem.Details, want) {
+		t.Fatalf("problem got %s: %v", w.Body, err)
+	}
+}
+
+// poolErr is an internal error with details
+type poolErr struct{}
+
+func (poolErr) Error() string        { return "connect 10.0.0.5:5432: connection refused" }
+func (poolErr) Code() int            { return http.StatusServiceUnavailable }
+func (poolErr) ErrorCode() string    { return "DB_UNAVAILABLE" }
+func (poolErr) Details() interface{} { return map[string]string{"host": "10.0.0.5"} }
+
+func TestExposeErrorDetails(t *testing.T) {
+	svc := testMS{
+		"query":  testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, poolErr{} }},
+		"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo},
+	}
+	var reported error
+	h := testHandler(t, Config{OnServerError: func(_ *http.Request, _ string, err error, _ int) { reported = err }}, svc)
+	w := do(h, http.MethodGet, "/query", "")
+	if info := errorBody(t, w.Body.Bytes()); w.Code != http.StatusServiceUnavailable ||
+		!reflect.DeepEqual(info, ErrorInfo{Code: http.StatusServiceUnavailable, ErrorCode: "DB_UNAVAILABLE", Message: "service unavailable"}) {
+		t.Fatalf("hidden error got %d %+v", w.Code, info)
+	}
+	if reported == nil {
+		t.Fatal("hidden error not reported")
+	}
+	if w := do(h, http.MethodPost, "/create", `{}`); w.Code != http.StatusBadRequest || errorBody(t, w.Body.Bytes()).Message == "bad request" {
+		t.Fatalf("client error got %d %s", w.Code, w.Body)
+	}
+
+	w = do(testHandler(t, Config{ExposeErrorDetails: true}, svc), http.MethodGet, "/query", "")
+	if info := errorBody(t, w.Body.Bytes()); info.Message == "service unavailable" || !reflect.DeepEqual(info.Details, map[string]interface{}{"host": "10.0.0.5"}) {
+		t.Fatalf("exposed error got %+v", info)
+	}
+}
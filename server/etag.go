@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-msvc/errors"
+)
+
+// etagFor returns a strong ETag for the encoded response body before compression
+// a gzip response has its own ETag as its bytes differ from the uncompressed response
+func etagFor(body []byte, gzipped bool) string {
+	sum := sha256.Sum256(body)
+	if gzipped {
+		return `"` + hex.EncodeToString(sum[:]) + `-gzip"`
+	}
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches returns true if the If-None-Match header lists the etag
+// using weak comparison as required for If-None-Match
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified returns true for a GET or HEAD request that already has the etag
+func notModified(httpReq *http.Request, etag string) bool {
+	if httpReq.Method != http.MethodGet && httpReq.Method != http.MethodHead {
+		return false
+	}
+	ifNoneMatch := httpReq.Header.Get("If-None-Match")
+	return ifNoneMatch != "" && etagMatches(ifNoneMatch, etag)
+}
+
+// ErrPreconditionFailed can be returned by a handler to reject a stale
+// conditional update with 412 Precondition Failed, see CheckPreconditions
+var ErrPreconditionFailed = errors.Errorc(http.StatusPreconditionFailed, "precondition failed")
+
+// IfMatch returns the entity tags of the If-Match request header, including "*"
+func IfMatch(ctx context.Context) ([]string, bool) {
+	etags, ok := ctx.Value(ifMatchKey).([]string)
+	return etags, ok
+}
+
+// IfUnmodifiedSince returns the time of a valid If-Unmodified-Since request header
+func IfUnmodifiedSince(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(ifUnmodifiedSinceKey).(time.Time)
+	return t, ok
+}
+
+// CheckPreconditions returns ErrPreconditionFailed unless the current etag and
+// last modification time of the resource satisfy the conditional request headers.
+// If-Match uses strong comparison, and If-Unmodified-Since is ignored when If-Match is present.
+// Pass "" or a zero time when the resource has no etag or modification time.
+func CheckPreconditions(ctx context.Context, etag string, lastModified time.Time) error {
+	if etags, ok := IfMatch(ctx); ok {
+		for _, candidate := range etags {
+			if (candidate == "*" && etag != "") || (candidate == etag && !strings.HasPrefix(etag, "W/")) {
+				return nil
+			}
+		}
+		return ErrPreconditionFailed
+	}
+	if since, ok := IfUnmodifiedSince(ctx); ok && !lastModified.IsZero() && lastModified.Truncate(time.Second).After(since) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// addPreconditions puts the conditional update headers of httpReq in ctx
+func addPreconditions(ctx *requestContext, httpReq *http.Request) {
+	if values := httpReq.Header.Values("If-Match"); len(values) > 0 {
+		etags := []string{}
+		for _, value := range values {
+			for _, etag := range strings.Split(value, ",") {
+				if etag = strings.TrimSpace(etag); etag != "" {
+					etags = append(etags, etag)
+				}
+			}
+		}
+		ctx.with(ifMatchKey, etags)
+	}
+	if value := httpReq.Header.Get("If-Unmodified-Since"); value != "" {
+		if since, err := http.ParseTime(value); err == nil {
+			ctx.with(ifUnmodifiedSinceKey, since) //invalid dates are ignored as required by RFC 7232
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+func TestETag(t *testing.T) {
+	h := testHandler(t, Config{ETag: true}, testMS{"get": testOper{handle: result("ok")}})
+	w := do(h, http.MethodGet, "/get", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) || len(etag) != 66 {
+		t.Fatalf("got %d ETag:%q", w.Code, etag)
+	}
+	if w := do(h, http.MethodGet, "/get", "", "If-None-Match", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Fatalf("match got %d %q", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodGet, "/get", "", "If-None-Match", `"other", W/`+etag); w.Code != http.StatusNotModified {
+		t.Fatalf("weak match in list got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/get", "", "If-None-Match", `"other"`); w.Code != http.StatusOK || w.Header().Get("ETag") != etag || w.Body.String() != `"ok"` {
+		t.Fatalf("mismatch got %d %q", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/get", "", "If-None-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("POST got %d", w.Code)
+	}
+}
+
+func TestETagWithGzip(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+	h := testHandler(t, Config{ETag: true, Gzip: true}, testMS{
+		"large": testOper{handle: result(large)},
+		"small": testOper{handle: result("ok")},
+	})
+	plain := do(h, http.MethodGet, "/large", "")
+	gzipped := do(h, http.MethodGet, "/large", "", "Accept-Encoding", "gzip")
+	if gzipped.Header().Get("Content-Encoding") != "gzip" || plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("encodings %v %v", plain.Header(), gzipped.Header())
+	}
+	if plain.Header().Get("ETag") == gzipped.Header().Get("ETag") {
+		t.Fatalf("gzip response has the ETag of the uncompressed response")
+	}
+	if w := do(h, http.MethodGet, "/large", "", "Accept-Encoding", "gzip", "If-None-Match", plain.Header().Get("ETag")); w.Code != http.StatusOK {
+		t.Fatalf("uncompressed ETag matched the gzip response: %d", w.Code)
+	}
+	w := do(h, http.MethodGet, "/large", "", "Accept-Encoding", "gzip", "If-None-Match", gzipped.Header().Get("ETag"))
+	if w.Code != http.StatusNotModified || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("gzip ETag got %d %v", w.Code, w.Header())
+	}
+	for _, r := range []*http.Response{plain.Result(), do(h, http.MethodGet, "/small", "", "Accept-Encoding", "gzip").Result()} {
+		if r.Header.Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("uncompressed response without Vary: %v", r.Header)
+		}
+	}
+}
+
+func TestPreconditions(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := testHandler(t, Config{}, testMS{"updateUser": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		if err := CheckPreconditions(ctx, `"v2"`, modified); err != nil {
+			return nil, err
+		}
+		return "updated", nil
+	}}})
+	for _, tc := range []struct {
+		header []string
+		want   int
+	}{
+		{nil, http.StatusOK},
+		{[]string{"If-Match", `"v2"`}, http.StatusOK},
+		{[]string{"If-Match", `"v1", "v2"`}, http.StatusOK},
+		{[]string{"If-Match", "*"}, http.StatusOK},
+		{[]string{"If-Match", `"v1"`}, http.StatusPreconditionFailed},
+		{[]string{"If-Match", `W/"v2"`}, http.StatusPreconditionFailed},
+		{[]string{"If-Unmodified-Since", modified.Format(http.TimeFormat)}, http.StatusOK},
+		{[]string{"If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed},
+		{[]string{"If-Unmodified-Since", "yesterday"}, http.StatusOK},
+		{[]string{"If-Match", `"v2"`, "If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+	} {
+		if w := do(h, http.MethodPut, "/updateUser", "", tc.header...); w.Code != tc.want {
+			t.Errorf("%q got %d, expected %d", tc.header, w.Code, tc.want)
+		}
+	}
+}
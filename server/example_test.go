@@ -0,0 +1,43 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+
+	"github.com/go-msvc/http/server"
+	"github.com/go-msvc/ms"
+)
+
+type greetReq struct {
+	Name string `json:"name"`
+}
+
+type greetOper struct{}
+
+func (greetOper) ReqType() reflect.Type { return reflect.TypeOf(greetReq{}) }
+
+func (greetOper) Handle(ctx ms.Context, req interface{}) (interface{}, error) {
+	return "hello " + req.(greetReq).Name, nil
+}
+
+type greeter struct{}
+
+func (greeter) Oper(name string) (ms.Oper, bool) { return greetOper{}, name == "greet" }
+
+func (greeter) OperNames() []string { return []string{"greet"} }
+
+func (greeter) NewContext() ms.Context { return context.Background() }
+
+func ExampleHandler() {
+	h, err := server.Handler(greeter{})
+	if err != nil {
+		panic(err)
+	}
+	httpRes := httptest.NewRecorder()
+	h.ServeHTTP(httpRes, httptest.NewRequest("POST", "/greet", strings.NewReader(`{"name":"bob"}`)))
+	fmt.Println(httpRes.Code, httpRes.Body.String())
+	// Output: 200 "hello bob"
+}
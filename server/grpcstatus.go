@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gRPC-Web style status trailers, see Config.GRPCStatusTrailers
+const (
+	grpcStatusTrailer  = "Grpc-Status"
+	grpcMessageTrailer = "Grpc-Message"
+)
+
+// grpcCode maps an HTTP status code to the closest gRPC status code
+func grpcCode(httpCode int) int {
+	switch {
+	case httpCode < 400:
+		return 0 //OK
+	case httpCode == http.StatusBadRequest:
+		return 3 //INVALID_ARGUMENT
+	case httpCode == http.StatusUnauthorized:
+		return 16 //UNAUTHENTICATED
+	case httpCode == http.StatusForbidden:
+		return 7 //PERMISSION_DENIED
+	case httpCode == http.StatusNotFound:
+		return 5 //NOT_FOUND
+	case httpCode == http.StatusConflict:
+		return 10 //ABORTED
+	case httpCode == http.StatusPreconditionFailed:
+		return 9 //FAILED_PRECONDITION
+	case httpCode == http.StatusTooManyRequests:
+		return 8 //RESOURCE_EXHAUSTED
+	case httpCode == 499:
+		return 1 //CANCELLED
+	case httpCode < 500:
+		return 9 //FAILED_PRECONDITION
+	case httpCode == http.StatusNotImplemented:
+		return 12 //UNIMPLEMENTED
+	case httpCode == http.StatusServiceUnavailable:
+		return 14 //UNAVAILABLE
+	case httpCode == http.StatusGatewayTimeout:
+		return 4 //DEADLINE_EXCEEDED
+	}
+	return 13 //INTERNAL
+}
+
+// grpcMessage percent-encodes message as required for the grpc-message trailer
+func grpcMessage(message string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(message) {
+		if b < ' ' || b > '~' || b == '%' {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		} else {
+			encoded.WriteByte(b)
+		}
+	}
+	return encoded.String()
+}
+
+// declareGRPCTrailers announces the trailers, which must be done before the header is written
+func declareGRPCTrailers(httpRes *responseWriter) {
+	httpRes.Header().Set("Trailer", grpcStatusTrailer+", "+grpcMessageTrailer)
+	httpRes.trailers = true
+}
+
+// setGRPCTrailers sets the trailers once the response is written
+func setGRPCTrailers(httpRes *responseWriter, code int, err error) {
+	httpRes.Header().Set(grpcStatusTrailer, strconv.Itoa(grpcCode(code)))
+	if err != nil {
+		httpRes.Header().Set(grpcMessageTrailer, grpcMessage(err.Error()))
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+func TestGRPCStatusTrailers(t *testing.T) {
+	svr := httptest.NewServer(testHandler(t, Config{GRPCStatusTrailers: true}, testMS{
+		"get":    testOper{handle: result("ok")},
+		"locked": testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, ErrPreconditionFailed }},
+		"lookup": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorc(http.StatusNotFound, "no such user")
+		}},
+	}))
+	defer svr.Close()
+	for path, want := range map[string][2]string{
+		"/get":     {"0", ""},
+		"/locked":  {"9", ""},
+		"/lookup":  {"5", "lookup"}, //the message names the operation
+		"/missing": {"5", ""},
+	} {
+		httpRes, err := http.Get(svr.URL + path)
+		if err != nil {
+			t.Fatalf("%s failed: %+v", path, err)
+		}
+		io.ReadAll(httpRes.Body) //trailers are read after the body
+		httpRes.Body.Close()
+		status, message := httpRes.Trailer.Get("Grpc-Status"), httpRes.Trailer.Get("Grpc-Message")
+		if status != want[0] || (want[0] != "0" && message == "") || (want[0] == "0" && message != "") || !strings.Contains(message, want[1]) {
+			t.Errorf("%s trailers %v, expected status %s", path, httpRes.Trailer, want[0])
+		}
+	}
+}
+
+func TestGRPCMessage(t *testing.T) {
+	if got := grpcMessage("100% café\n"); got != "100%25 caf%C3%A9%0A" {
+		t.Fatalf("encoded as %q", got)
+	}
+}
+
+func TestGRPCStatusTrailersOff(t *testing.T) {
+	svr := httptest.NewServer(testHandler(t, Config{}, testMS{"get": testOper{handle: result("ok")}}))
+	defer svr.Close()
+	httpRes, err := http.Get(svr.URL + "/get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(httpRes.Body)
+	httpRes.Body.Close()
+	if len(httpRes.Trailer) != 0 || httpRes.Header.Get("Trailer") != "" {
+		t.Fatalf("trailers %v without GRPCStatusTrailers", httpRes.Trailer)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func TestH2C(t *testing.T) {
+	_, url := startServer(t, Config{H2C: true}, testMS{"proto": testOper{handle: result("ok")}})
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	httpRes, err := client.Get(url + "/proto")
+	if err != nil {
+		t.Fatalf("h2c request failed: %+v", err)
+	}
+	httpRes.Body.Close()
+	if httpRes.StatusCode != http.StatusOK || httpRes.ProtoMajor != 2 {
+		t.Fatalf("got %d over %s", httpRes.StatusCode, httpRes.Proto)
+	}
+
+	//HTTP/1.1 clients are still served
+	if httpRes, err := http.Get(url + "/proto"); err != nil || httpRes.ProtoMajor != 1 {
+		t.Fatalf("HTTP/1.1 request failed: %v", err)
+	} else {
+		httpRes.Body.Close()
+	}
+
+	if err := (Config{Addr: "localhost", H2C: true, CertFile: "server.crt", KeyFile: "server.key"}).Validate(); err == nil || !strings.Contains(err.Error(), "h2c") {
+		t.Fatalf("h2c with TLS got %v", err)
+	}
+}
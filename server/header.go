@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// stagedHeaders are response headers set by a handler through its context
+// they are applied to the response after the handler returns, also when it fails
+type stagedHeaders struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+// SetResponseHeader stages a response header from an operation handler, e.g. Location
+// on a 201 Created result or a pagination Link, replacing earlier values of key
+// it returns false when ctx does not belong to a request of this server
+func SetResponseHeader(ctx context.Context, key, value string) bool {
+	staged, ok := ctx.Value(responseHeadersKey).(*stagedHeaders)
+	if !ok {
+		return false
+	}
+	staged.mu.Lock()
+	defer staged.mu.Unlock()
+	staged.header.Set(key, value)
+	return true
+}
+
+// AddResponseHeader is like SetResponseHeader but keeps earlier values of key
+func AddResponseHeader(ctx context.Context, key, value string) bool {
+	staged, ok := ctx.Value(responseHeadersKey).(*stagedHeaders)
+	if !ok {
+		return false
+	}
+	staged.mu.Lock()
+	defer staged.mu.Unlock()
+	staged.header.Add(key, value)
+	return true
+}
+
+// applyTo copies the staged headers into the response headers
+func (h *stagedHeaders) applyTo(header http.Header) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, values := range h.header {
+		header[key] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"createUser": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			SetResponseHeader(ctx, "Location", "/users/1")
+			AddResponseHeader(ctx, "Link", `</users?page=1>; rel="first"`)
+			AddResponseHeader(ctx, "Link", `</users?page=3>; rel="last"`)
+			return statusRes{status: http.StatusCreated, ID: "1"}, nil
+		}},
+		"lockUser": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			SetResponseHeader(ctx, "Retry-After", "5")
+			return nil, ErrPreconditionFailed
+		}},
+	})
+	w := do(h, http.MethodPost, "/createUser", "")
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/users/1" || len(w.Header().Values("Link")) != 2 {
+		t.Fatalf("create got %d %v", w.Code, w.Header())
+	}
+	if w := do(h, http.MethodPost, "/lockUser", ""); w.Code != http.StatusPreconditionFailed || w.Header().Get("Retry-After") != "5" {
+		t.Fatalf("failed handler got %d %v", w.Code, w.Header())
+	}
+	if SetResponseHeader(context.Background(), "Location", "/users/1") {
+		t.Fatal("header staged outside a request")
+	}
+}
+
+func TestDedupResponseHeaders(t *testing.T) {
+	var calls int32
+	h := testHandler(t, Config{DedupRequests: true}, testMS{
+		"createUser": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			SetResponseHeader(ctx, "Location", "/users/1")
+			return statusRes{status: http.StatusCreated, ID: "1"}, nil
+		}},
+	})
+	locations := make([]string, 3)
+	var wg sync.WaitGroup
+	for i := range locations {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			locations[i] = do(h, http.MethodPost, "/createUser", "").Header().Get("Location")
+		}(i)
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("handler called %d times", got)
+	}
+	for i, location := range locations {
+		if location != "/users/1" {
+			t.Errorf("request %d got Location %q", i, location)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-msvc/errors"
+)
+
+const (
+	defaultHealthPath = "/healthz"
+	defaultReadyPath  = "/readyz"
+)
+
+// ReadyChecker is optionally implemented by the micro-service to report
+// whether it is ready to serve requests
+type ReadyChecker interface {
+	Ready() error
+}
+
+type healthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func writeHealth(httpRes http.ResponseWriter, code int, status healthStatus) {
+	httpRes.Header().Set("Content-Type", "application/json")
+	httpRes.Header().Set("Cache-Control", "no-store")
+	httpRes.WriteHeader(code)
+	json.NewEncoder(httpRes).Encode(status)
+}
+
+// serveHealth reports that the process is alive
+func (s *server) serveHealth(httpRes http.ResponseWriter, httpReq *http.Request) {
+	writeHealth(httpRes, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// serveReady reports 503 unless the server is ready to serve operations
+func (s *server) serveReady(httpRes http.ResponseWriter, httpReq *http.Request) {
+	if err := s.ready(); err != nil {
+		writeHealth(httpRes, http.StatusServiceUnavailable, healthStatus{Status: "not ready", Error: err.Error()})
+		return
+	}
+	writeHealth(httpRes, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+func (s *server) ready() error {
+	if !s.listening.Load() {
+		return errors.Errorf("starting")
+	}
+	if s.draining.Load() {
+		return errors.Errorf("shutting down")
+	}
+	if s.config.ReadyCheck != nil {
+		return s.config.ReadyCheck()
+	}
+	if checker, ok := s.ms.(ReadyChecker); ok {
+		return checker.Ready()
+	}
+	return nil
+}
+
+// startupComplete returns true once the server is listening and the micro-service
+// has been ready, so that operations registered during startup are not reported as unknown
+// later readiness failures are only reported by the readiness endpoint
+func (s *server) startupComplete() bool {
+	if s.started.Load() {
+		return true
+	}
+	if s.ready() != nil {
+		return false
+	}
+	s.started.Store(true)
+	return true
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var ready error
+	handled := false
+	h := testHandler(t, Config{ReadyCheck: func() error { return ready }}, testMS{
+		"healthz": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: func(ms.Context, interface{}) (interface{}, error) {
+			handled = true
+			return nil, nil
+		}},
+	})
+	for _, path := range []string{"/healthz", "/readyz"} {
+		w := do(h, http.MethodPost, path, "not json")
+		if w.Code != http.StatusOK || w.Body.String() != "{\"status\":\"ok\"}\n" {
+			t.Fatalf("%s got %d %s", path, w.Code, w.Body)
+		}
+	}
+	if handled {
+		t.Fatal("health path routed to the operation")
+	}
+	ready = errors.Error("warming up")
+	if w := do(h, http.MethodGet, "/readyz", ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("not ready got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/healthz", ""); w.Code != http.StatusOK {
+		t.Fatalf("health while not ready got %d", w.Code)
+	}
+}
+
+// registeringMS is not ready until its operations are registered
+type registeringMS struct {
+	testMS
+	registered *bool
+}
+
+func (m registeringMS) Ready() error {
+	if !*m.registered {
+		return errors.Error("registering operations")
+	}
+	return nil
+}
+
+func TestStartupGate(t *testing.T) {
+	registered := false
+	h, err := (Config{Addr: "localhost"}).Handler(registeringMS{testMS{"get": testOper{handle: result("ok")}}, &registered})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/get", "/unknown", "/readyz"} {
+		w := do(h, http.MethodGet, path, "")
+		if w.Code != http.StatusServiceUnavailable || (path != "/readyz" && w.Header().Get("Retry-After") == "") {
+			t.Fatalf("%s before ready got %d %v", path, w.Code, w.Header())
+		}
+	}
+	if w := do(h, http.MethodGet, "/healthz", ""); w.Code != http.StatusOK {
+		t.Fatalf("health before ready got %d", w.Code)
+	}
+	registered = true
+	if w := do(h, http.MethodGet, "/get", ""); w.Code != http.StatusOK {
+		t.Fatalf("after ready got %d", w.Code)
+	}
+	//once started, only readiness reports later failures
+	registered = false
+	if w := do(h, http.MethodGet, "/get", ""); w.Code != http.StatusOK {
+		t.Fatalf("after startup got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/readyz", ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ready after startup got %d", w.Code)
+	}
+}
+
+func TestStartupGateBeforeListening(t *testing.T) {
+	s, err := (Config{Addr: "localhost"}).newServer(testMS{"get": testOper{handle: result("ok")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := do(s.httpServer.Handler, http.MethodGet, "/get", ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("before Serve got %d", w.Code)
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-msvc/errors"
+)
+
+// IdempotencyKeyHeader marks a request that may be retried safely
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// defaultIdempotencyTTL is used when IdempotencyConfig.TTL is not set
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyConfig replays the first successful response of an operation
+// for later requests of the same caller with the same Idempotency-Key header
+type IdempotencyConfig struct {
+	// TTL that responses are kept, defaults to 24h
+	TTL time.Duration
+	// Store defaults to NewMemoryIdempotencyStore()
+	Store IdempotencyStore `json:"-"`
+}
+
+func (c IdempotencyConfig) Validate() error {
+	if c.TTL < 0 {
+		return errors.Errorf("negative ttl:%v", c.TTL)
+	}
+	return nil
+}
+
+// StoredResponse is a response kept for replay
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Fingerprint identifies the request, a reused key with another fingerprint is rejected
+	Fingerprint string
+}
+
+// ErrIdempotencyInFlight is returned by IdempotencyStore.Reserve while
+// another request with the same key is being handled
+var ErrIdempotencyInFlight = errors.Error("request with the same idempotency key is in progress")
+
+// IdempotencyStore keeps responses by idempotency key
+// implement it to share responses between instances, e.g. in Redis
+type IdempotencyStore interface {
+	// Reserve returns the stored response for the key, or reserves the key and returns nil
+	// it returns ErrIdempotencyInFlight if the key is already reserved
+	Reserve(key string, ttl time.Duration) (*StoredResponse, error)
+	// Complete stores the response for a reserved key
+	Complete(key string, res StoredResponse, ttl time.Duration) error
+	// Release removes the reservation so that the request can be retried
+	Release(key string) error
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore for a single instance
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{entries: map[string]idempotencyEntry{}, lastPrune: time.Now()}
+}
+
+type memoryIdempotencyStore struct {
+	mutex     sync.Mutex
+	entries   map[string]idempotencyEntry
+	lastPrune time.Time
+}
+
+type idempotencyEntry struct {
+	res     *StoredResponse //nil while in flight
+	expires time.Time
+}
+
+func (m *memoryIdempotencyStore) Reserve(key string, ttl time.Duration) (*StoredResponse, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	m.prune(now)
+	if e, ok := m.entries[key]; ok && !now.After(e.expires) {
+		if e.res == nil {
+			return nil, ErrIdempotencyInFlight
+		}
+		return e.res, nil
+	}
+	m.entries[key] = idempotencyEntry{expires: now.Add(ttl)}
+	return nil, nil
+}
+
+// prune removes expired entries, at most once a minute so that Reserve does not scan them all
+func (m *memoryIdempotencyStore) prune(now time.Time) {
+	if now.Sub(m.lastPrune) < time.Minute {
+		return
+	}
+	m.lastPrune = now
+	for key, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *memoryIdempotencyStore) Complete(key string, res StoredResponse, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries[key] = idempotencyEntry{res: &res, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Release(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// idempotencyStoreKey scopes the idempotency key to the operation and its caller,
+// identified by the principal of the Authenticator, else by the Authorization header,
+// so that callers cannot replay each other's responses
+func idempotencyStoreKey(prefix, operName string, principal interface{}, httpReq *http.Request, key string) string {
+	caller := "authorization:" + httpReq.Header.Get("Authorization")
+	if principal != nil {
+		caller = fmt.Sprintf("principal:%+v", principal)
+	}
+	sum := sha256.Sum256([]byte(caller))
+	return prefix + "/" + operName + ":" + hex.EncodeToString(sum[:]) + ":" + key
+}
+
+// requestFingerprint identifies a request by method, URL and body
+func requestFingerprint(httpReq *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{httpReq.Method, httpReq.URL.RequestURI()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay writes a stored response
+func replay(httpRes http.ResponseWriter, stored *StoredResponse) {
+	for name, values := range stored.Header {
+		httpRes.Header()[name] = values
+	}
+	httpRes.Header().Set("Idempotent-Replayed", "true")
+	httpRes.WriteHeader(stored.Status)
+	httpRes.Write(stored.Body)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+// counter returns a handler that returns the number of times it was called
+func counter(calls *int32) func(ms.Context, interface{}) (interface{}, error) {
+	return func(ms.Context, interface{}) (interface{}, error) {
+		return int(atomic.AddInt32(calls, 1)), nil
+	}
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls int32
+	h := testHandler(t, Config{Idempotency: &IdempotencyConfig{}}, testMS{
+		"create": testOper{reqType: reflect.TypeOf(contactReq{}), handle: counter(&calls)},
+	})
+	first := do(h, http.MethodPost, "/create", `{"name":"a"}`, IdempotencyKeyHeader, "k1")
+	second := do(h, http.MethodPost, "/create", `{"name":"a"}`, IdempotencyKeyHeader, "k1")
+	if first.Body.String() != "1" || second.Body.String() != "1" || second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("got %s and %s %v", first.Body, second.Body, second.Header())
+	}
+	if w := do(h, http.MethodPost, "/create", `{"name":"a"}`, IdempotencyKeyHeader, "k2"); w.Body.String() != "2" {
+		t.Fatalf("other key got %s", w.Body)
+	}
+	if w := do(h, http.MethodPost, "/create", `{"name":"a"}`); w.Body.String() != "3" {
+		t.Fatalf("no key got %s", w.Body)
+	}
+	if w := do(h, http.MethodPost, "/create", `{"name":"b"}`, IdempotencyKeyHeader, "k1"); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key with another body got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/create?x=1", `{"name":"a"}`, IdempotencyKeyHeader, "k1"); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key with another URL got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestIdempotencyCallers(t *testing.T) {
+	var calls int32
+	svc := testMS{"create": testOper{handle: counter(&calls)}}
+	h := testHandler(t, Config{Idempotency: &IdempotencyConfig{}}, svc)
+	if w := do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1", "Authorization", "Bearer alice"); w.Body.String() != "1" {
+		t.Fatalf("alice got %s", w.Body)
+	}
+	if w := do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1", "Authorization", "Bearer bob"); w.Body.String() != "2" || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("bob got the response of alice: %s", w.Body)
+	}
+
+	h = testHandler(t, Config{
+		Idempotency: &IdempotencyConfig{},
+		Authenticator: func(httpReq *http.Request) (interface{}, error) {
+			return httpReq.Header.Get("X-User"), nil
+		},
+	}, svc)
+	if w := do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1", "X-User", "alice"); w.Body.String() != "3" {
+		t.Fatalf("alice got %s", w.Body)
+	}
+	if w := do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1", "X-User", "bob"); w.Body.String() != "4" {
+		t.Fatalf("bob got the response of alice: %s", w.Body)
+	}
+	if w := do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1", "X-User", "alice"); w.Body.String() != "3" {
+		t.Fatalf("alice retry got %s", w.Body)
+	}
+}
+
+func TestIdempotencyConcurrent(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int32
+	h := testHandler(t, Config{Idempotency: &IdempotencyConfig{}}, testMS{"create": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+			return nil, errors.Error("failed")
+		}
+		return "created", nil
+	}}})
+	done := make(chan int)
+	go func() { done <- do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1").Code }()
+	<-started
+	if w := do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1"); w.Code != http.StatusConflict {
+		t.Fatalf("concurrent request got %d", w.Code)
+	}
+	close(release)
+	if code := <-done; code != http.StatusInternalServerError {
+		t.Fatalf("first request got %d", code)
+	}
+	//the failed response was not stored, so the request can be retried
+	if w := do(h, http.MethodPost, "/create", "", IdempotencyKeyHeader, "k1"); w.Code != http.StatusOK || w.Body.String() != strconv.Quote("created") {
+		t.Fatalf("retry got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore().(*memoryIdempotencyStore)
+	if err := store.Complete("old", StoredResponse{Status: http.StatusOK}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	//an expired entry is ignored before it is pruned
+	if res, err := store.Reserve("old", time.Hour); res != nil || err != nil {
+		t.Fatalf("expired entry got %v %v", res, err)
+	}
+	store.Complete("other", StoredResponse{Status: http.StatusOK}, -time.Second)
+	store.prune(time.Now().Add(30 * time.Second))
+	if len(store.entries) != 2 {
+		t.Fatalf("pruned within a minute: %v", store.entries)
+	}
+	store.prune(time.Now().Add(2 * time.Hour))
+	if len(store.entries) != 0 {
+		t.Fatalf("not pruned: %v", store.entries)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-msvc/ms"
+)
+
+// OperationInfo describes an operation served by Config.OperationsPath
+type OperationInfo struct {
+	Name       string      `json:"name"`
+	Methods    []string    `json:"methods,omitempty"` //empty when any method is accepted
+	Path       string      `json:"path,omitempty"`    //template of a PathOper
+	Deprecated bool        `json:"deprecated,omitempty"`
+	ReqType    string      `json:"reqType,omitempty"` //empty when the operation takes no request
+	Fields     []FieldInfo `json:"fields,omitempty"`
+	ResType    string      `json:"resType,omitempty"` //only for a ResponseTyped operation
+}
+
+// FieldInfo describes a field of a struct request
+type FieldInfo struct {
+	Name     string `json:"name"` //Go field name
+	Type     string `json:"type"`
+	In       string `json:"in"`  //body, query, path or header
+	Key      string `json:"key"` //JSON, query, path or header name
+	Required bool   `json:"required,omitempty"`
+}
+
+// Operations returns a summary of the operations of svc sorted by name
+func Operations(svc ms.MicroService) []OperationInfo {
+	operNames := append([]string{}, svc.OperNames()...)
+	sort.Strings(operNames)
+	infos := []OperationInfo{}
+	for _, operName := range operNames {
+		oper, ok := svc.Oper(operName)
+		if !ok {
+			continue
+		}
+		info := OperationInfo{Name: operName, Deprecated: deprecated(http.Header{}, oper)}
+		if methodOper, ok := oper.(MethodOper); ok {
+			info.Methods = methodOper.Methods()
+		}
+		if pathOper, ok := oper.(PathOper); ok {
+			info.Path = pathOper.Path()
+		}
+		if reqType := oper.ReqType(); reqType != nil {
+			info.ReqType = reqType.String()
+			info.Fields = fieldInfos(reqType)
+		}
+		if responseTyped, ok := oper.(ResponseTyped); ok && responseTyped.ResType() != nil {
+			info.ResType = responseTyped.ResType().String()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// fieldInfos lists the exported fields of a struct type and where they are bound from,
+// with the fields of embedded structs in place
+func fieldInfos(t reflect.Type) []FieldInfo {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := []FieldInfo{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue //unexported
+		}
+		bound := false
+		for _, tag := range []string{"path", "query", "header"} {
+			options := strings.Split(f.Tag.Get(tag), ",")
+			if options[0] == "" || options[0] == "-" {
+				continue
+			}
+			field := FieldInfo{Name: f.Name, Type: f.Type.String(), In: parameterTags[tag], Key: options[0], Required: tag == "path"}
+			for _, option := range options[1:] {
+				field.Required = field.Required || option == "required"
+			}
+			fields = append(fields, field)
+			bound = true
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || (bound && name == "") {
+			continue
+		}
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, fieldInfos(f.Type)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, FieldInfo{Name: f.Name, Type: f.Type.String(), In: "body", Key: name})
+	}
+	return fields
+}
+
+// serveOperations lists the operations of the micro-service of the server
+func (s *server) serveOperations(httpRes http.ResponseWriter, httpReq *http.Request) {
+	httpRes.Header().Set("Content-Type", "application/json")
+	httpRes.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(httpRes).Encode(Operations(s.ms)); err != nil {
+		s.log.Errorf("failed to write operations: %+v", err)
+	}
+}
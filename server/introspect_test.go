@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Paging struct {
+	Limit int `query:"limit"`
+}
+
+type itemReq struct {
+	Paging
+	ID     string `path:"id"`
+	Tenant string `header:"X-Tenant-ID,required"`
+	Name   string `json:"name"`
+	Age    int
+	Secret string `json:"-"`
+	note   string
+}
+
+func TestOperationsPath(t *testing.T) {
+	if err := (Config{Addr: "localhost", OperationsPath: "/_operations"}).Validate(); err == nil {
+		t.Fatal("operationsPath accepted without adminPort or debug")
+	}
+	h := testHandler(t, Config{Debug: true, OperationsPath: "/_operations"}, testMS{
+		"getItem": routeOper{testOper{reqType: reflect.TypeOf(itemReq{}), handle: echo}, "/items/{id}"},
+		"ping":    methodsOper{testOper{handle: result("pong")}, []string{http.MethodGet}},
+		"status":  typedOper{testOper{handle: result(statusRes{})}, reflect.TypeOf(statusRes{})},
+		"old":     deprecatedOper{testOper{handle: result("old")}, time.Now(), time.Time{}},
+	})
+	w := do(h, http.MethodGet, "/_operations", "")
+	var infos []OperationInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("got %d %s: %v", w.Code, w.Body, err)
+	}
+	want := []OperationInfo{
+		{Name: "getItem", Path: "/items/{id}", ReqType: "server.itemReq", Fields: []FieldInfo{
+			{Name: "Limit", Type: "int", In: "query", Key: "limit"},
+			{Name: "ID", Type: "string", In: "path", Key: "id", Required: true},
+			{Name: "Tenant", Type: "string", In: "header", Key: "X-Tenant-ID", Required: true},
+			{Name: "Name", Type: "string", In: "body", Key: "name"},
+			{Name: "Age", Type: "int", In: "body", Key: "Age"},
+		}},
+		{Name: "old", Deprecated: true},
+		{Name: "ping", Methods: []string{http.MethodGet}},
+		{Name: "status", ResType: "server.statusRes"},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Fatalf("got %+v\nwant %+v", infos, want)
+	}
+}
+
+func TestOperationsPathOnAdminPort(t *testing.T) {
+	s, url := startServer(t, Config{AdminPort: freePort(t), OperationsPath: "/_operations"}, testMS{"ping": testOper{handle: result("pong")}})
+	for target, want := range map[string]int{
+		"http://" + s.AdminAddr() + "/_operations": http.StatusOK,
+		url + "/_operations":                       http.StatusNotFound,
+	} {
+		httpRes, err := http.Get(target)
+		if err != nil {
+			t.Fatalf("%s failed: %+v", target, err)
+		}
+		httpRes.Body.Close()
+		if httpRes.StatusCode != want {
+			t.Errorf("%s got %d, expected %d", target, httpRes.StatusCode, want)
+		}
+	}
+}
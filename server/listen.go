@@ -0,0 +1,27 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+
+	"github.com/go-msvc/errors"
+)
+
+// listen creates the listener for the configured transport
+func (s *server) listen() (net.Listener, error) {
+	if s.config.UnixSocket != "" {
+		//remove a socket left behind by a process that did not shut down cleanly
+		if info, err := os.Stat(s.config.UnixSocket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(s.config.UnixSocket); err != nil {
+				return nil, errors.Wrapf(err, "failed to remove stale socket %s", s.config.UnixSocket)
+			}
+		}
+		//the socket file is removed when the listener is closed
+		return net.Listen("unix", s.config.UnixSocket)
+	}
+	//like net.Listen, accepted connections get TCP keep-alive probes after 15s idle unless configured
+	//the accept backlog is the OS limit, e.g. net.core.somaxconn on Linux, as Go does not expose it
+	lc := net.ListenConfig{KeepAlive: s.config.TCPKeepAlive}
+	return lc.Listen(context.Background(), "tcp", s.addr)
+}
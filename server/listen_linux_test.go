@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTCPKeepAlive(t *testing.T) {
+	for keepAlive, want := range map[time.Duration][2]int{
+		0:               {1, 15}, //the net/http default
+		7 * time.Second: {1, 7},
+		-1:              {0, 0},
+	} {
+		s := &server{config: Config{TCPKeepAlive: keepAlive}, addr: "127.0.0.1:0"}
+		l, err := s.listen()
+		if err != nil {
+			t.Fatalf("failed to listen: %+v", err)
+		}
+		go func() {
+			if conn, err := net.Dial("tcp", l.Addr().String()); err == nil {
+				conn.Close()
+			}
+		}()
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatalf("failed to accept: %+v", err)
+		}
+		rawConn, err := conn.(*net.TCPConn).SyscallConn()
+		if err != nil {
+			t.Fatalf("no raw connection: %+v", err)
+		}
+		var got [2]int
+		rawConn.Control(func(fd uintptr) {
+			got[0], _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+			if got[0] != 0 {
+				got[1], _ = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+			}
+		})
+		conn.Close()
+		l.Close()
+		if got != want {
+			t.Errorf("keepAlive:%v got keep-alive %d after %ds, want %v", keepAlive, got[0], got[1], want)
+		}
+	}
+}
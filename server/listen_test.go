@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve serves c until the test ends and returns the server once it is listening
+func serve(t *testing.T, c Config, svc testMS) *server {
+	t.Helper()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("invalid config: %+v", err)
+	}
+	msServer, err := c.Create(svc)
+	if err != nil {
+		t.Fatalf("failed to create server: %+v", err)
+	}
+	s := msServer.(*server)
+	listening := make(chan net.Addr, 1)
+	s.config.OnListen = func(addr net.Addr) { listening <- addr }
+	served := make(chan error, 1)
+	go func() { served <- s.Serve() }()
+	select {
+	case <-listening:
+	case err := <-served:
+		t.Fatalf("failed to serve: %+v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not listen")
+	}
+	t.Cleanup(func() {
+		s.Shutdown(context.Background())
+		<-served
+	})
+	return s
+}
+
+func TestUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported")
+	}
+	socket := filepath.Join(t.TempDir(), "http.sock")
+	s := serve(t, Config{UnixSocket: socket}, testMS{"ping": testOper{handle: result("pong")}})
+	if s.Addr() != socket {
+		t.Fatalf("Addr() %q", s.Addr())
+	}
+	client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}}}
+	if body := get(t, client, "http://unix/ping"); body != `"pong"` {
+		t.Fatalf("got %s", body)
+	}
+	s.Shutdown(context.Background())
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatalf("socket not removed after shutdown: %v", err)
+	}
+}
+
+func TestPortZero(t *testing.T) {
+	s := serve(t, Config{Addr: "127.0.0.1", Port: 0}, testMS{"ping": testOper{handle: result("pong")}})
+	addr := s.Addr()
+	if !strings.HasPrefix(addr, "127.0.0.1:") || strings.HasSuffix(addr, ":0") {
+		t.Fatalf("Addr() %q", addr)
+	}
+	if body := get(t, http.DefaultClient, "http://"+addr+"/ping"); body != `"pong"` {
+		t.Fatalf("got %s", body)
+	}
+}
+
+func TestValidateTransport(t *testing.T) {
+	for name, c := range map[string]Config{
+		"socket and addr":  {UnixSocket: "/tmp/http.sock", Addr: "localhost"},
+		"socket and port":  {UnixSocket: "/tmp/http.sock", Port: 8080},
+		"neither":          {},
+		"invalid port":     {Addr: "localhost", Port: 70000},
+		"socket keepalive": {UnixSocket: "/tmp/http.sock", TCPKeepAlive: time.Minute},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
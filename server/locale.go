@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultLocale = "en"
+
+// Translator returns the error message for a locale, e.g. from a message catalog
+// keyed by the error code or type, or "" to keep err.Error()
+type Translator func(locale string, code int, err error) string
+
+// localizedError replaces the message of an error for the error writer
+// while keeping its code and details
+type localizedError struct {
+	error
+	message string
+}
+
+func (e localizedError) Error() string { return e.message }
+
+func (e localizedError) Unwrap() error { return e.error }
+
+func (e localizedError) Details() interface{} {
+	return errorDetails(e.error)
+}
+
+// localize returns err with the message from the translator when it has one
+func localize(translator Translator, locale string, code int, err error) error {
+	if message := translator(locale, code, err); message != "" {
+		return localizedError{error: err, message: message}
+	}
+	return err
+}
+
+// negotiateLocale picks the supported locale from the Accept-Language header with the highest q
+// a tag like "fr-CH" also matches a supported "fr", and defaultLocale is used when there is no match
+func negotiateLocale(acceptLanguage string, supported []string, defaultLocale string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	tags := []tag{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{name: strings.TrimSpace(fields[0]), q: 1}
+		for _, param := range fields[1:] {
+			if qs := strings.TrimSpace(param); strings.HasPrefix(qs, "q=") {
+				q, err := strconv.ParseFloat(qs[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				t.q = q
+			}
+		}
+		if t.name != "" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q }) //earlier entries win ties
+	for _, t := range tags {
+		if t.name == "*" {
+			return defaultLocale
+		}
+		if locale, ok := matchLocale(t.name, supported); ok {
+			return locale
+		}
+	}
+	return defaultLocale
+}
+
+func matchLocale(name string, supported []string) (string, bool) {
+	for _, locale := range supported {
+		if strings.EqualFold(name, locale) {
+			return locale, true
+		}
+	}
+	if i := strings.Index(name, "-"); i > 0 {
+		return matchLocale(name[:i], supported)
+	}
+	return "", false
+}
+
+// requestLocale is the locale of error messages for the request
+func (s *server) requestLocale(httpReq *http.Request) string {
+	return negotiateLocale(httpReq.Header.Get("Accept-Language"), s.config.Locales, s.config.DefaultLocale)
+}
+
+// Locale returns the locale negotiated from the Accept-Language header when Config.Translator is set
+// so that handlers can localize their own results
+func Locale(ctx context.Context) (string, bool) {
+	locale, ok := ctx.Value(localeKey).(string)
+	return locale, ok
+}
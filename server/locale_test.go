@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+func TestTranslator(t *testing.T) {
+	messages := map[string]string{"en": "user not found", "fr": "utilisateur introuvable"}
+	h := testHandler(t, Config{Locales: []string{"en", "fr"}, Translator: func(locale string, code int, err error) string {
+		if code == http.StatusNotFound {
+			return messages[locale]
+		}
+		return ""
+	}}, testMS{
+		"bad": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo},
+		"locale": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			locale, _ := Locale(ctx)
+			return locale, nil
+		}},
+	})
+	for acceptLanguage, want := range map[string]string{
+		"fr-CH, en;q=0.5": "fr",
+		"de, en;q=0.1":    "en",
+		"de":              "en",
+		"":                "en",
+		"fr;q=0, *":       "en",
+	} {
+		w := do(h, http.MethodGet, "/missing", "", "Accept-Language", acceptLanguage)
+		if w.Code != http.StatusNotFound || errorBody(t, w.Body.Bytes()).Message != messages[want] || w.Header().Get("Content-Language") != want {
+			t.Errorf("%q got %d %s %q", acceptLanguage, w.Code, w.Body, w.Header().Get("Content-Language"))
+		}
+		if w := do(h, http.MethodGet, "/locale", "", "Accept-Language", acceptLanguage); w.Body.String() != `"`+want+`"` {
+			t.Errorf("%q handler locale %s", acceptLanguage, w.Body)
+		}
+	}
+	//errors without a translation keep their message
+	if w := do(h, http.MethodPost, "/bad", `{}`, "Accept-Language", "fr"); w.Code != http.StatusBadRequest || errorBody(t, w.Body.Bytes()).Message == "" {
+		t.Fatalf("untranslated error got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/logger"
+)
+
+// leveledLogger is the part of the logger used by a server
+type leveledLogger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// parseLogLevel returns the level for Config.LogLevel, defaulting to info
+func parseLogLevel(level string) (logger.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return logger.LevelDebug, nil
+	case "", "info":
+		return logger.LevelInfo, nil
+	case "error":
+		return logger.LevelError, nil
+	}
+	return logger.LevelInfo, errors.Errorf("unknown logLevel:%q, expecting debug, info or error", level)
+}
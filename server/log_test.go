@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/go-msvc/logger"
+)
+
+func TestLogLevel(t *testing.T) {
+	for level, want := range map[string]logger.Level{
+		"":      logger.LevelInfo,
+		"info":  logger.LevelInfo,
+		"DEBUG": logger.LevelDebug,
+		"error": logger.LevelError,
+	} {
+		if got, err := parseLogLevel(level); err != nil || got != want {
+			t.Errorf("level %q got %v, %v", level, got, err)
+		}
+	}
+	if err := (Config{Addr: "localhost", LogLevel: "verbose"}).Validate(); err == nil {
+		t.Fatal("unknown log level accepted")
+	}
+	if _, err := (Config{Addr: "localhost", LogLevel: "error"}).Handler(testMS{}); err != nil {
+		t.Fatalf("error level rejected: %+v", err)
+	}
+}
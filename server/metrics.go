@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsCollector records the outcome of each operation request
+// implement it to export metrics to your own system
+type MetricsCollector interface {
+	Observe(operName string, code int, duration time.Duration)
+}
+
+// defaultBuckets are the upper bounds in seconds of the duration histogram
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// NewPrometheusMetrics returns an in-memory collector that also serves
+// its metrics in the Prometheus text exposition format
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		counts:    map[countKey]uint64{},
+		durations: map[string]*histogram{},
+		breakers:  map[string]BreakerState{},
+	}
+}
+
+type PrometheusMetrics struct {
+	mutex     sync.Mutex
+	counts    map[countKey]uint64
+	durations map[string]*histogram
+	breakers  map[string]BreakerState
+}
+
+type countKey struct {
+	operName string
+	code     int
+}
+
+type histogram struct {
+	buckets []uint64 //count per defaultBuckets, not cumulative
+	sum     float64
+	count   uint64
+}
+
+func (m *PrometheusMetrics) Observe(operName string, code int, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.counts[countKey{operName: operName, code: code}]++
+	h, ok := m.durations[operName]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(defaultBuckets))}
+		m.durations[operName] = h
+	}
+	seconds := duration.Seconds()
+	for i, upper := range defaultBuckets {
+		if seconds <= upper {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Count returns the number of requests recorded for the operation and status code
+func (m *PrometheusMetrics) Count(operName string, code int) uint64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.counts[countKey{operName: operName, code: code}]
+}
+
+// ObserveBreaker records the state of the circuit breaker of an operation
+func (m *PrometheusMetrics) ObserveBreaker(operName string, state BreakerState) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.breakers[operName] = state
+}
+
+// BreakerState returns the last recorded circuit breaker state of the operation
+func (m *PrometheusMetrics) BreakerState(operName string) BreakerState {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.breakers[operName]
+}
+
+func (m *PrometheusMetrics) ServeHTTP(httpRes http.ResponseWriter, httpReq *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("# HELP http_requests_total Number of HTTP requests by operation and status code.\n")
+	sb.WriteString("# TYPE http_requests_total counter\n")
+	keys := make([]countKey, 0, len(m.counts))
+	for k := range m.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operName != keys[j].operName {
+			return keys[i].operName < keys[j].operName
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "http_requests_total{operation=%q,code=\"%d\"} %d\n", k.operName, k.code, m.counts[k])
+	}
+
+	sb.WriteString("# HELP http_request_duration_seconds Duration of HTTP requests by operation.\n")
+	sb.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	operNames := make([]string, 0, len(m.durations))
+	for operName := range m.durations {
+		operNames = append(operNames, operName)
+	}
+	sort.Strings(operNames)
+	for _, operName := range operNames {
+		h := m.durations[operName]
+		var cumulative uint64
+		for i, upper := range defaultBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(&sb, "http_request_duration_seconds_bucket{operation=%q,le=\"%g\"} %d\n", operName, upper, cumulative)
+		}
+		fmt.Fprintf(&sb, "http_request_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", operName, h.count)
+		fmt.Fprintf(&sb, "http_request_duration_seconds_sum{operation=%q} %g\n", operName, h.sum)
+		fmt.Fprintf(&sb, "http_request_duration_seconds_count{operation=%q} %d\n", operName, h.count)
+	}
+
+	if len(m.breakers) > 0 {
+		sb.WriteString("# HELP http_circuit_breaker_state Circuit breaker state by operation, 0 closed, 1 open, 2 half-open.\n")
+		sb.WriteString("# TYPE http_circuit_breaker_state gauge\n")
+		operNames = operNames[:0]
+		for operName := range m.breakers {
+			operNames = append(operNames, operName)
+		}
+		sort.Strings(operNames)
+		for _, operName := range operNames {
+			fmt.Fprintf(&sb, "http_circuit_breaker_state{operation=%q} %d\n", operName, m.breakers[operName])
+		}
+	}
+
+	httpRes.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	httpRes.Write([]byte(sb.String()))
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	h := testHandler(t, Config{MetricsPath: "/metrics", Metrics: metrics}, testMS{
+		"get": testOper{handle: result("ok")},
+		"fail": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorc(http.StatusConflict, "taken")
+		}},
+	})
+	do(h, http.MethodGet, "/get", "")
+	do(h, http.MethodGet, "/get", "")
+	do(h, http.MethodGet, "/fail", "")
+
+	w := do(h, http.MethodGet, "/metrics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics got %d", w.Code)
+	}
+	for _, line := range []string{
+		`http_requests_total{operation="get",code="200"} 2`,
+		`http_requests_total{operation="fail",code="409"} 1`,
+		`http_request_duration_seconds_count{operation="get"} 2`,
+	} {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("missing %s in\n%s", line, w.Body)
+		}
+	}
+	if strings.Contains(w.Body.String(), `operation="metrics"`) {
+		t.Fatal("metrics path recorded as an operation")
+	}
+}
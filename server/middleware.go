@@ -0,0 +1,20 @@
+package server
+
+import "net/http"
+
+// Middleware wraps an http.Handler, e.g. to add authentication or logging
+type Middleware func(http.Handler) http.Handler
+
+// Use appends middleware to wrap around the server handler
+// the first registered middleware runs outermost
+func (c *Config) Use(mw ...Middleware) {
+	c.Middleware = append(c.Middleware, mw...)
+}
+
+// chain wraps h in the middleware so that mw[0] is called first
+func chain(h http.Handler, mw []Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
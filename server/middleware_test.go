@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+// appendHeader is middleware that appends name to the X-Order response header
+func appendHeader(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(httpRes http.ResponseWriter, httpReq *http.Request) {
+			httpRes.Header().Add("X-Order", name)
+			next.ServeHTTP(httpRes, httpReq)
+		})
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	c := Config{}
+	c.Use(appendHeader("first"))
+	c.Use(appendHeader("second"), appendHeader("third"))
+	h := testHandler(t, c, testMS{"get": testOper{handle: result("ok")}})
+	w := do(h, http.MethodGet, "/get", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d", w.Code)
+	}
+	if order := strings.Join(w.Header().Values("X-Order"), ","); order != "first,second,third" {
+		t.Fatalf("middleware ran in order %s", order)
+	}
+}
+
+func TestHandleHooks(t *testing.T) {
+	var calls []string
+	h := testHandler(t, Config{
+		BeforeHandle: func(ctx ms.Context, operName string, req interface{}) error {
+			calls = append(calls, fmt.Sprintf("before %s %v", operName, req))
+			if operName == "delete" {
+				return errors.Errorc(http.StatusForbidden, "deletes are audited")
+			}
+			return nil
+		},
+		AfterHandle: func(ctx ms.Context, operName string, res interface{}, err error) {
+			calls = append(calls, fmt.Sprintf("after %s %v %v", operName, res, err))
+		},
+	}, testMS{
+		"get":    testOper{reqType: reflect.TypeOf(queryReq{}), handle: result("ok")},
+		"fail":   testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, errors.Errorf("failed") }},
+		"delete": testOper{handle: func(ms.Context, interface{}) (interface{}, error) { t.Fatal("aborted handler ran"); return nil, nil }},
+	})
+	if w := do(h, http.MethodGet, "/get?name=a", ""); w.Code != http.StatusOK {
+		t.Fatalf("get got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/fail", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("fail got %d", w.Code)
+	}
+	if w := do(h, http.MethodDelete, "/delete", ""); w.Code != http.StatusForbidden || errorBody(t, w.Body.Bytes()).Message != "deletes are audited" {
+		t.Fatalf("delete got %d %s", w.Code, w.Body)
+	}
+	want := []string{"before get {", "after get ok <nil>", "before fail <nil>", "after fail <nil> failed", "before delete <nil>"}
+	if len(calls) != len(want) {
+		t.Fatalf("hooks called %q", calls)
+	}
+	for i := range want {
+		if !strings.HasPrefix(calls[i], want[i]) {
+			t.Fatalf("hooks called %q, expected %q", calls, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// mount is an http.Handler serving all paths under prefix
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+// newMounts returns the handlers sorted by longest prefix first
+// and fails when a prefix could shadow an operation or version
+func newMounts(handlers map[string]http.Handler, svc *service, versions map[string]*service) ([]mount, error) {
+	mounts := make([]mount, 0, len(handlers))
+	for prefix, handler := range handlers {
+		if err := checkPrefixRoutes(svc.routes, prefix); err != nil {
+			return nil, errors.Wrapf(err, "cannot serve handler on %s", prefix)
+		}
+		for version := range versions {
+			if strings.HasPrefix("/"+version+"/", prefix) || strings.HasPrefix(prefix, "/"+version+"/") {
+				return nil, errors.Errorf("handler on %s conflicts with version %s", prefix, version)
+			}
+		}
+		mounts = append(mounts, mount{prefix: prefix, handler: handler})
+	}
+	sort.Slice(mounts, func(i, j int) bool { return len(mounts[i].prefix) > len(mounts[j].prefix) })
+	return mounts, nil
+}
+
+// mounted returns the handler with the longest prefix of path, or nil
+func (s *server) mounted(path string) http.Handler {
+	for _, m := range s.mounts {
+		if strings.HasPrefix(path, m.prefix) {
+			return m.handler
+		}
+	}
+	return nil
+}
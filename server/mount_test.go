@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHandlers(t *testing.T) {
+	assets := fstest.MapFS{"app.js": {Data: []byte("console.log('ui')")}}
+	h := testHandler(t, Config{Handlers: map[string]http.Handler{
+		"/ui/":       http.StripPrefix("/ui/", http.FileServer(http.FS(assets))),
+		"/ui/admin/": http.HandlerFunc(func(httpRes http.ResponseWriter, _ *http.Request) { httpRes.Write([]byte("admin")) }),
+	}}, testMS{"get": testOper{handle: result("op")}})
+	for target, want := range map[string]string{
+		"/ui/app.js":     "console.log('ui')",
+		"/ui/admin/home": "admin", //longest prefix wins
+		"/get":           `"op"`,
+	} {
+		if w := do(h, http.MethodGet, target, ""); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%s got %d %q", target, w.Code, w.Body)
+		}
+	}
+}
+
+func TestHandlerConflicts(t *testing.T) {
+	svc := testMS{"orders": routeOper{testOper{handle: result("op")}, "/users/{id}/orders"}}
+	for _, prefix := range []string{"/users/", "/users/1/"} {
+		if _, err := (Config{Addr: "localhost", Handlers: map[string]http.Handler{prefix: http.NotFoundHandler()}}).Handler(svc); err == nil {
+			t.Errorf("handler on %s shadows an operation path", prefix)
+		}
+	}
+	for _, prefix := range []string{"/", "ui/", "/ui"} {
+		if err := (Config{Addr: "localhost", Handlers: map[string]http.Handler{prefix: http.NotFoundHandler()}}).Validate(); err == nil {
+			t.Errorf("handler prefix %q accepted", prefix)
+		}
+	}
+}
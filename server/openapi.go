@@ -0,0 +1,260 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+// parameterTags are bound from the URL or headers instead of the body
+var parameterTags = map[string]string{
+	"path":   "path",
+	"query":  "query",
+	"header": "header",
+}
+
+// OpenAPI returns an OpenAPI 3.0 document describing the operations of the micro-service
+func OpenAPI(svc ms.MicroService, title string, version string) map[string]interface{} {
+	paths := map[string]interface{}{}
+	operNames := append([]string{}, svc.OperNames()...)
+	sort.Strings(operNames)
+	for _, operName := range operNames {
+		oper, ok := svc.Oper(operName)
+		if !ok {
+			continue
+		}
+		methods := []string{http.MethodPost}
+		if methodOper, ok := oper.(MethodOper); ok && len(methodOper.Methods()) > 0 {
+			methods = methodOper.Methods()
+		}
+		pathOper, isPathOper := oper.(PathOper)
+		if isPathOper {
+			//path parameters are only bound from the template, operation ids must be unique
+			paths[pathOper.Path()] = openAPIPathItem(methods, openAPIOperation(operName, oper, true))
+			paths["/"+operName] = openAPIPathItem(methods, openAPIOperation(operName+"ByName", oper, false))
+		} else {
+			paths["/"+operName] = openAPIPathItem(methods, openAPIOperation(operName, oper, false))
+		}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+	}
+}
+
+func openAPIPathItem(methods []string, operation map[string]interface{}) map[string]interface{} {
+	item := map[string]interface{}{}
+	for _, method := range methods {
+		item[strings.ToLower(method)] = operation
+	}
+	return item
+}
+
+// openAPIOperation describes an operation, withPath includes the path parameters of its PathOper template
+func openAPIOperation(operationID string, oper ms.Oper, withPath bool) map[string]interface{} {
+	operation := map[string]interface{}{
+		"operationId": operationID,
+		"responses": map[string]interface{}{
+			"200":     map[string]interface{}{"description": "success"},
+			"204":     map[string]interface{}{"description": "success without result"},
+			"default": map[string]interface{}{"description": "error", "content": jsonContent(errorBodySchema())},
+		},
+	}
+	if deprecated(http.Header{}, oper) {
+		operation["deprecated"] = true
+	}
+	if responseTyped, ok := oper.(ResponseTyped); ok && responseTyped.ResType() != nil {
+		operation["responses"].(map[string]interface{})["200"] = map[string]interface{}{
+			"description": "success",
+			"content":     jsonContent(schemaFor(responseTyped.ResType(), map[reflect.Type]bool{})),
+		}
+	}
+	reqType := oper.ReqType()
+	if reqType == nil {
+		return operation
+	}
+	for reqType.Kind() == reflect.Ptr {
+		reqType = reqType.Elem()
+	}
+	if reqType.Kind() == reflect.Struct {
+		parameters := openAPIParameters(reqType, withPath)
+		if len(parameters) > 0 {
+			sort.Slice(parameters, func(i, j int) bool {
+				pi, pj := parameters[i].(map[string]interface{}), parameters[j].(map[string]interface{})
+				if pi["in"] != pj["in"] {
+					return pi["in"].(string) < pj["in"].(string)
+				}
+				return pi["name"].(string) < pj["name"].(string)
+			})
+			operation["parameters"] = parameters
+		}
+		if len(bodyFields(reqType)) == 0 {
+			return operation
+		}
+	}
+	operation["requestBody"] = map[string]interface{}{
+		"content": jsonContent(schemaFor(reqType, map[reflect.Type]bool{})),
+	}
+	return operation
+}
+
+// openAPIParameters describes the fields bound from the URL or headers,
+// including those of embedded structs like the binder
+func openAPIParameters(t reflect.Type, withPath bool) []interface{} {
+	parameters := []interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		for tag, in := range parameterTags {
+			options := strings.Split(f.Tag.Get(tag), ",")
+			if options[0] == "" || options[0] == "-" {
+				continue
+			}
+			if in == "path" && !withPath {
+				continue
+			}
+			required := in == "path"
+			for _, option := range options[1:] {
+				required = required || option == "required"
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":     options[0],
+				"in":       in,
+				"required": required,
+				"schema":   schemaFor(f.Type, map[reflect.Type]bool{}),
+			})
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			parameters = append(parameters, openAPIParameters(f.Type, withPath)...)
+		}
+	}
+	return parameters
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func errorBodySchema() map[string]interface{} {
+	return schemaFor(reflect.TypeOf(ErrorBody{}), map[reflect.Type]bool{})
+}
+
+// bodyFields returns the JSON names of the struct fields decoded from the body
+// the fields of embedded structs are promoted unless an outer field has the same name
+func bodyFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	embedded := []reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft) //also when unexported, like encoding/json
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		isParameter := false
+		for tag := range parameterTags {
+			if name := strings.Split(f.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+				isParameter = true
+			}
+		}
+		if isParameter && name == "" {
+			continue
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	for _, et := range embedded {
+		for name, f := range bodyFields(et) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = f
+			}
+		}
+	}
+	return fields
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the JSON schema of a Go type as encoded by encoding/json
+// visiting guards against recursive types
+func schemaFor(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == rawMessageType {
+		return map[string]interface{}{} //any JSON value, not base64 like other byte slices
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		properties := map[string]interface{}{}
+		for name, f := range bodyFields(t) {
+			properties[name] = schemaFor(f.Type, visiting)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// serveOpenAPI serves the OpenAPI document of the micro-service
+func (s *server) serveOpenAPI(httpRes http.ResponseWriter, httpReq *http.Request) {
+	doc := OpenAPI(s.ms, s.config.OpenAPITitle, s.config.OpenAPIVersion)
+	httpRes.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(httpRes).Encode(doc); err != nil {
+		s.log.Errorf("failed to write OpenAPI document: %+v", err)
+	}
+}
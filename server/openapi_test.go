@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type auditFields struct {
+	CreatedBy string `json:"createdBy"`
+}
+
+type updateUserReq struct {
+	auditFields
+	ID      string `path:"id"`
+	Verbose bool   `query:"verbose"`
+	Name    string `json:"name"`
+	Age     int    `json:"age,omitempty"`
+}
+
+// namesMS lists its operations in a fixed order from a shared slice
+type namesMS struct {
+	testMS
+	names []string
+}
+
+func (m namesMS) OperNames() []string { return m.names }
+
+func TestOpenAPI(t *testing.T) {
+	svc := namesMS{
+		testMS: testMS{
+			"updateUser": routeOper{testOper: testOper{reqType: reflect.TypeOf(updateUserReq{}), handle: echo}, path: "/users/{id}"},
+			"ping":       methodsOper{testOper: testOper{handle: result("pong")}, methods: []string{http.MethodGet}},
+		},
+		names: []string{"updateUser", "ping"},
+	}
+	b, err := json.Marshal(OpenAPI(svc, "users", "1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.names[0] != "updateUser" {
+		t.Fatalf("OperNames() sorted in place: %v", svc.names)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]interface{} `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" || len(doc.Paths) != 3 {
+		t.Fatalf("got %s", b)
+	}
+	if _, ok := doc.Paths["/ping"]["get"]; !ok {
+		t.Fatalf("missing GET /ping in %s", b)
+	}
+
+	byTemplate, byName := doc.Paths["/users/{id}"]["post"], doc.Paths["/updateUser"]["post"]
+	if byTemplate.OperationID == byName.OperationID {
+		t.Fatalf("duplicate operationId %s", byName.OperationID)
+	}
+	if len(byTemplate.Parameters) != 2 || byTemplate.Parameters[0].Name != "id" || byTemplate.Parameters[0].In != "path" || !byTemplate.Parameters[0].Required {
+		t.Fatalf("template parameters %+v", byTemplate.Parameters)
+	}
+	if len(byName.Parameters) != 1 || byName.Parameters[0].In != "query" {
+		t.Fatalf("path parameter on /updateUser: %+v", byName.Parameters)
+	}
+	properties := byTemplate.RequestBody.Content["application/json"].Schema.Properties
+	if len(properties) != 3 || properties["name"]["type"] != "string" || properties["age"]["type"] != "integer" || properties["createdBy"]["type"] != "string" {
+		t.Fatalf("body properties %v", properties)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	h := testHandler(t, Config{OpenAPIPath: "/openapi.json", OpenAPITitle: "users"}, testMS{"ping": testOper{handle: result("pong")}})
+	w := do(h, http.MethodGet, "/openapi.json", "")
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %s: %v", w.Code, w.Body, err)
+	}
+	if doc["info"].(map[string]interface{})["title"] != "users" || doc["paths"].(map[string]interface{})["/ping"] == nil {
+		t.Fatalf("got %s", w.Body)
+	}
+}
+
+func TestOpenAPIGenericReqTypes(t *testing.T) {
+	for reqType, want := range map[reflect.Type]string{
+		reflect.TypeOf(json.RawMessage{}):        `{}`,
+		reflect.TypeOf(map[string]interface{}{}): `{"additionalProperties":{},"type":"object"}`,
+	} {
+		b, _ := json.Marshal(schemaFor(reqType, map[reflect.Type]bool{}))
+		if string(b) != want {
+			t.Errorf("%v schema %s, want %s", reqType, b, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MethodOper is optionally implemented by an operation to restrict the HTTP
+// methods it accepts. Operations that do not implement it accept any method.
+// HEAD is accepted for operations accepting GET and answered without the body.
+type MethodOper interface {
+	Methods() []string
+}
+
+// StatusCoder is optionally implemented by an operation result to set the
+// success status code, e.g. 201 Created, instead of the default 200 OK
+// the body is omitted for 204 No Content and 304 Not Modified
+type StatusCoder interface {
+	Status() int
+}
+
+// ResponseTyped is optionally implemented by an operation to declare its result type
+// it is used to describe the response in OpenAPI and to check results when
+// Config.CheckResponseTypes is set
+type ResponseTyped interface {
+	ResType() reflect.Type
+}
+
+// resTypeMatches returns true if res is of the declared type, a pointer to it,
+// or implements it when the declared type is an interface
+func resTypeMatches(res interface{}, resType reflect.Type) bool {
+	t := reflect.TypeOf(res)
+	if t == resType {
+		return true
+	}
+	if t.Kind() == reflect.Ptr && t.Elem() == resType {
+		return true
+	}
+	return resType.Kind() == reflect.Interface && t.Implements(resType)
+}
+
+// Cacheable is optionally implemented by an operation or its result to set
+// the Cache-Control header of successful responses, e.g. "public, max-age=60"
+// an empty value marks the response as not cacheable with "no-store"
+// a result implementing it takes precedence over the operation
+type Cacheable interface {
+	CacheControl() string
+}
+
+// cacheControl returns the Cache-Control value, or "" when not declared
+func cacheControl(oper interface{}, res interface{}) string {
+	cacheable, ok := res.(Cacheable)
+	if !ok {
+		if cacheable, ok = oper.(Cacheable); !ok {
+			return ""
+		}
+	}
+	if value := cacheable.CacheControl(); value != "" {
+		return value
+	}
+	return "no-store"
+}
+
+// BodyRequiredOper is optionally implemented by an operation to reject
+// requests without a body with 400 instead of handling a zero value request
+type BodyRequiredOper interface {
+	BodyRequired() bool
+}
+
+// Deprecatable is optionally implemented by an operation that is being phased out
+// a non-zero DeprecatedSince adds "Deprecation: true" to responses, and a non-zero SunsetAt
+// a Sunset header with the date after which the operation may be removed
+type Deprecatable interface {
+	DeprecatedSince() time.Time
+	SunsetAt() time.Time
+}
+
+// RawBodyOper is optionally implemented by an operation to read the request body
+// as a stream, e.g. a large upload, the request passed to Handle is then an io.Reader
+// of the body instead of a decoded ReqType, still limited by Config.MaxBodyBytes
+type RawBodyOper interface {
+	RawBody() bool
+}
+
+// QueryBodyOper is optionally implemented by a read operation to take its request fields
+// from either the query string or the JSON body, e.g. GET /find?name=a or POST /find {"name":"a"}
+// when QueryBody is true, query parameters are also bound to fields by their JSON name
+// before the body is decoded, so body values take precedence
+type QueryBodyOper interface {
+	QueryBody() bool
+}
+
+// TimeoutOper is optionally implemented by an operation to override
+// Config.HandlerTimeout, a zero timeout uses the configured value
+type TimeoutOper interface {
+	Timeout() time.Duration
+}
+
+// LongPollOper is optionally implemented by an operation whose handler blocks until data
+// is available, its context expires after PollTimeout, or Config.PollTimeout when zero,
+// instead of HandlerTimeout, and a handler that did not return a result by then gets
+// 204 No Content so that the client polls again
+type LongPollOper interface {
+	PollTimeout() time.Duration
+}
+
+func methodAllowed(method string, allowed []string) bool {
+	for _, m := range allowed {
+		if strings.EqualFold(m, method) || (method == http.MethodHead && strings.EqualFold(m, http.MethodGet)) {
+			return true
+		}
+	}
+	return false
+}
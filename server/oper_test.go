@@ -0,0 +1,224 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+// methodsOper accepts only the given methods
+type methodsOper struct {
+	testOper
+	methods []string
+}
+
+func (o methodsOper) Methods() []string { return o.methods }
+
+func TestMethodNotAllowed(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"createUser": methodsOper{testOper{handle: result("created")}, []string{http.MethodPost, http.MethodPut}},
+		"any":        testOper{handle: result("ok")},
+	})
+	if w := do(h, http.MethodPost, "/createUser", ""); w.Code != http.StatusOK {
+		t.Fatalf("POST got %d", w.Code)
+	}
+	w := do(h, http.MethodDelete, "/createUser", "")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST, PUT" {
+		t.Fatalf("DELETE got %d Allow:%q", w.Code, w.Header().Get("Allow"))
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete, http.MethodPatch} {
+		if w := do(h, method, "/any", ""); w.Code != http.StatusOK {
+			t.Fatalf("%s without MethodOper got %d", method, w.Code)
+		}
+	}
+}
+
+// statusRes is a result with a custom success status
+type statusRes struct {
+	status int
+	ID     string `json:"id,omitempty"`
+}
+
+func (r statusRes) Status() int { return r.status }
+
+func TestStatusCoder(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"create": testOper{handle: result(statusRes{status: http.StatusCreated, ID: "42"})},
+		"accept": testOper{handle: result(statusRes{status: http.StatusAccepted})},
+		"delete": testOper{handle: result(statusRes{status: http.StatusNoContent, ID: "42"})},
+	})
+	if w := do(h, http.MethodPost, "/create", ""); w.Code != http.StatusCreated || w.Body.String() != `{"id":"42"}` || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("create got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/accept", ""); w.Code != http.StatusAccepted || w.Body.String() != `{}` {
+		t.Fatalf("accept got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/delete", ""); w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("delete got %d %q", w.Code, w.Body)
+	}
+}
+
+// typedOper declares its result type
+type typedOper struct {
+	testOper
+	resType reflect.Type
+}
+
+func (o typedOper) ResType() reflect.Type { return o.resType }
+
+func TestCheckResponseTypes(t *testing.T) {
+	h, log := loggedHandler(t, Config{CheckResponseTypes: true}, testMS{
+		"right":   typedOper{testOper: testOper{handle: result(contactReq{Name: "a"})}, resType: reflect.TypeOf(contactReq{})},
+		"pointer": typedOper{testOper: testOper{handle: result(&contactReq{Name: "a"})}, resType: reflect.TypeOf(contactReq{})},
+		"wrong":   typedOper{testOper: testOper{handle: result("a")}, resType: reflect.TypeOf(contactReq{})},
+	})
+	for _, operName := range []string{"right", "pointer", "wrong"} {
+		if w := do(h, http.MethodGet, "/"+operName, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s got %d", operName, w.Code)
+		}
+	}
+	if log.contains("right returned") || log.contains("pointer returned") {
+		t.Fatalf("matching type reported: %v", log.lines)
+	}
+	if !log.contains("wrong returned string instead of declared server.contactReq") {
+		t.Fatalf("mismatch not logged: %v", log.lines)
+	}
+}
+
+// cachedRes is a result with its own Cache-Control value
+type cachedRes struct {
+	cacheControl string
+	Name         string `json:"name"`
+}
+
+func (r cachedRes) CacheControl() string { return r.cacheControl }
+
+// cachedOper declares the Cache-Control value of its results
+type cachedOper struct {
+	testOper
+	cacheControl string
+}
+
+func (o cachedOper) CacheControl() string { return o.cacheControl }
+
+func TestCacheControl(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"oper":       cachedOper{testOper: testOper{handle: result("a")}, cacheControl: "public, max-age=60"},
+		"result":     cachedOper{testOper: testOper{handle: result(cachedRes{cacheControl: "private, max-age=5"})}, cacheControl: "public, max-age=60"},
+		"noStore":    cachedOper{testOper: testOper{handle: result("a")}},
+		"undeclared": testOper{handle: result("a")},
+	})
+	for operName, want := range map[string]string{
+		"oper":       "public, max-age=60",
+		"result":     "private, max-age=5",
+		"noStore":    "no-store",
+		"undeclared": "",
+	} {
+		if w := do(h, http.MethodGet, "/"+operName, ""); w.Code != http.StatusOK || w.Header().Get("Cache-Control") != want {
+			t.Errorf("%s got %d Cache-Control:%q, expected %q", operName, w.Code, w.Header().Get("Cache-Control"), want)
+		}
+	}
+}
+
+func TestHead(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"getUser":    methodsOper{testOper{handle: result(map[string]string{"name": "bob"})}, []string{http.MethodGet}},
+		"createUser": methodsOper{testOper{handle: result("created")}, []string{http.MethodPost}},
+		"any":        testOper{handle: result("hello")},
+	})
+	for _, path := range []string{"/getUser", "/any"} {
+		get := do(h, http.MethodGet, path, "")
+		head := do(h, http.MethodHead, path, "")
+		if head.Code != http.StatusOK || head.Body.Len() != 0 {
+			t.Fatalf("HEAD %s got %d %q", path, head.Code, head.Body)
+		}
+		if want := strconv.Itoa(get.Body.Len()); head.Header().Get("Content-Length") != want || get.Header().Get("Content-Length") != want {
+			t.Fatalf("HEAD %s Content-Length %q, GET %q, expected %s", path, head.Header().Get("Content-Length"), get.Header().Get("Content-Length"), want)
+		}
+		if head.Header().Get("Content-Type") != get.Header().Get("Content-Type") {
+			t.Fatalf("HEAD %s Content-Type %q", path, head.Header().Get("Content-Type"))
+		}
+	}
+	if w := do(h, http.MethodHead, "/createUser", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("HEAD of a POST operation got %d", w.Code)
+	}
+}
+
+// bodyOper requires a request body
+type bodyOper struct{ testOper }
+
+func (bodyOper) BodyRequired() bool { return true }
+
+func TestBodyRequired(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"required": bodyOper{testOper{reqType: reflect.TypeOf(contactReq{}), handle: echo}},
+		"optional": testOper{reqType: reflect.TypeOf(contactReq{}), handle: echo},
+	})
+	for _, contentType := range []string{"", "application/json", "application/x-www-form-urlencoded"} {
+		w := do(h, http.MethodPost, "/required", "", "Content-Type", contentType)
+		if w.Code != http.StatusBadRequest || errorBody(t, w.Body.Bytes()).ErrorCode != ErrorCodeBodyRequired {
+			t.Errorf("required %q without body got %d %s", contentType, w.Code, w.Body)
+		}
+		if w := do(h, http.MethodPost, "/optional", "", "Content-Type", contentType); w.Code != http.StatusOK || w.Body.String() != `{"name":"","email":""}` {
+			t.Errorf("optional %q without body got %d %s", contentType, w.Code, w.Body)
+		}
+	}
+	if w := do(h, http.MethodPost, "/required", `{"name":"a"}`); w.Code != http.StatusOK {
+		t.Fatalf("required JSON body got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/required", "name=a", "Content-Type", "application/x-www-form-urlencoded"); w.Code != http.StatusOK || w.Body.String() != `{"name":"a","email":""}` {
+		t.Fatalf("required form body got %d %s", w.Code, w.Body)
+	}
+}
+
+// rawOper reads the request body as a stream
+type rawOper struct{ testOper }
+
+func (rawOper) RawBody() bool { return true }
+
+func TestRawBody(t *testing.T) {
+	h := testHandler(t, Config{MaxBodyBytes: 1024}, testMS{
+		"ingest": rawOper{testOper{reqType: reflect.TypeOf(contactReq{}), handle: func(_ ms.Context, req interface{}) (interface{}, error) {
+			n, err := io.Copy(io.Discard, req.(io.Reader))
+			if err != nil {
+				return nil, err
+			}
+			return map[string]int64{"length": n}, nil
+		}}},
+	})
+	if w := do(h, http.MethodPost, "/ingest", strings.Repeat("x", 1000)); w.Code != http.StatusOK || w.Body.String() != `{"length":1000}` {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/ingest", strings.Repeat("x", 2000)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestStrictBody(t *testing.T) {
+	svc := testMS{
+		"ping":   testOper{handle: result("pong")},
+		"create": testOper{reqType: reflect.TypeOf(contactReq{}), handle: echo},
+	}
+	for _, strict := range []bool{false, true} {
+		h := testHandler(t, Config{StrictBody: strict}, svc)
+		if w := do(h, http.MethodPost, "/ping", ""); w.Code != http.StatusOK {
+			t.Errorf("strict:%v without body got %d %s", strict, w.Code, w.Body)
+		}
+		w := do(h, http.MethodPost, "/ping", `{"a":1}`)
+		if !strict && (w.Code != http.StatusOK || w.Body.String() != `"pong"`) {
+			t.Errorf("ignored body got %d %s", w.Code, w.Body)
+		}
+		if strict && (w.Code != http.StatusBadRequest || errorBody(t, w.Body.Bytes()).ErrorCode != ErrorCodeUnexpectedBody) {
+			t.Errorf("strict body got %d %s", w.Code, w.Body)
+		}
+		//a request type without a body is the zero value
+		if w := do(h, http.MethodGet, "/create", ""); w.Code != http.StatusOK || w.Body.String() != `{"name":"","email":""}` {
+			t.Errorf("strict:%v empty request got %d %s", strict, w.Code, w.Body)
+		}
+	}
+}
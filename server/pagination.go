@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TotalCountHeader is the number of items in the collection of a Paginated result
+	TotalCountHeader = "X-Total-Count"
+
+	defaultCursorParam = "cursor"
+)
+
+// Paginated is optionally implemented by a result that is one page of a collection
+// the result is encoded as usual, with pagination headers from its Page
+type Paginated interface {
+	Page() Page
+}
+
+// Page describes a page of a collection
+// Total is the collection size or negative when unknown,
+// Offset is the index of the first item in the page and Count the number of items in it,
+// and the cursors are query parameter values to request the next and previous pages, empty when there is none
+type Page struct {
+	Total      int64
+	Offset     int64
+	Count      int
+	NextCursor string
+	PrevCursor string
+}
+
+// setPageHeaders sets X-Total-Count and Link headers with the URL of the request
+// and the cursor query parameter replaced, and Content-Range when the request
+// has a "Range: items=..." header, returning true for a 206 Partial Content response
+func setPageHeaders(header http.Header, httpReq *http.Request, page Page, cursorParam string) bool {
+	if page.Total >= 0 {
+		header.Set(TotalCountHeader, strconv.FormatInt(page.Total, 10))
+	}
+	for _, link := range []struct{ rel, cursor string }{{"next", page.NextCursor}, {"prev", page.PrevCursor}} {
+		rel, cursor := link.rel, link.cursor
+		if cursor == "" {
+			continue
+		}
+		u := *httpReq.URL
+		query := u.Query()
+		query.Set(cursorParam, cursor)
+		u.RawQuery = query.Encode()
+		header.Add("Link", fmt.Sprintf("<%s>; rel=%q", u.RequestURI(), rel))
+	}
+	if !strings.HasPrefix(httpReq.Header.Get("Range"), "items=") || page.Count == 0 {
+		return false
+	}
+	total := "*"
+	if page.Total >= 0 {
+		total = strconv.FormatInt(page.Total, 10)
+	}
+	header.Set("Content-Range", fmt.Sprintf("items %d-%d/%s", page.Offset, page.Offset+int64(page.Count)-1, total))
+	return true
+}
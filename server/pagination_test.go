@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+// userPage is one page of users
+type userPage struct {
+	Users []string `json:"users"`
+	page  Page
+}
+
+func (p userPage) Page() Page { return p.page }
+
+func TestPaginated(t *testing.T) {
+	page := Page{Total: 10, Offset: 2, Count: 2, NextCursor: "c4", PrevCursor: "c0"}
+	h := testHandler(t, Config{CursorParam: "after"}, testMS{
+		"list": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return userPage{Users: []string{"c", "d"}, page: page}, nil
+		}},
+	})
+
+	w := do(h, http.MethodGet, "/list?limit=2&after=c2", "")
+	if w.Code != http.StatusOK || w.Body.String() != `{"users":["c","d"]}` || w.Header().Get(TotalCountHeader) != "10" || w.Header().Get("Content-Range") != "" {
+		t.Fatalf("got %d %s %v", w.Code, w.Body, w.Header())
+	}
+	if links := w.Header().Values("Link"); !reflect.DeepEqual(links, []string{`</list?after=c4&limit=2>; rel="next"`, `</list?after=c0&limit=2>; rel="prev"`}) {
+		t.Fatalf("got links %q", links)
+	}
+
+	if w := do(h, http.MethodGet, "/list", "", "Range", "items=2-3"); w.Code != http.StatusPartialContent || w.Header().Get("Content-Range") != "items 2-3/10" {
+		t.Fatalf("range got %d %v", w.Code, w.Header())
+	}
+	page = Page{Total: -1, Offset: 4, Count: 2}
+	w = do(h, http.MethodGet, "/list", "", "Range", "items=4-5")
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Range") != "items 4-5/*" || w.Header().Get(TotalCountHeader) != "" || w.Header().Get("Link") != "" {
+		t.Fatalf("unknown total got %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// PprofPrefix is the path under which Config.EnablePprof serves net/http/pprof
+const PprofPrefix = "/debug/pprof/"
+
+func newPprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PprofPrefix, pprof.Index) //also serves named profiles like heap and goroutine
+	mux.HandleFunc(PprofPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PprofPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(PprofPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofPrefix+"trace", pprof.Trace)
+	return mux
+}
+
+// isPprofPath returns true for paths served by the pprof handler
+func isPprofPath(path string) bool {
+	return path == strings.TrimSuffix(PprofPrefix, "/") || strings.HasPrefix(path, PprofPrefix)
+}
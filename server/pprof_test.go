@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPprof(t *testing.T) {
+	svc := testMS{"get": testOper{handle: result("ok")}}
+	h := testHandler(t, Config{EnablePprof: true}, svc)
+	for _, target := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"} {
+		if w := do(h, http.MethodGet, target, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s got %d", target, w.Code)
+		}
+	}
+	if w := do(h, http.MethodGet, "/get", ""); w.Code != http.StatusOK {
+		t.Fatalf("operation got %d", w.Code)
+	}
+	if w := do(testHandler(t, Config{}, svc), http.MethodGet, "/debug/pprof/", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("disabled got %d", w.Code)
+	}
+}
+
+func TestPprofConflict(t *testing.T) {
+	svc := testMS{"dump": routeOper{testOper{handle: result("ok")}, "/debug/{kind}/dump"}}
+	if _, err := (Config{Addr: "localhost", EnablePprof: true}).Handler(svc); err == nil {
+		t.Fatal("expected an operation under /debug/pprof/ to conflict")
+	}
+	if _, err := (Config{Addr: "localhost", EnablePprof: true, AdminPort: 9}).Handler(svc); err != nil {
+		t.Fatalf("pprof on the admin port conflicts: %+v", err)
+	}
+}
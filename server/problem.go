@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+const problemMediaType = "application/problem+json"
+
+// ProblemDetails is an RFC 7807 error body, with the errorCode and details
+// of the error as extension members
+type ProblemDetails struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	ErrorCode string      `json:"errorCode,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// ProblemTyper is optionally implemented by an error to set the problem type URI
+type ProblemTyper interface {
+	ProblemType() string
+}
+
+// NewProblemWriter returns an ErrorWriter of application/problem+json bodies
+// defaultType is the type of errors without a ProblemTyper, "about:blank" when empty
+func NewProblemWriter(defaultType string) ErrorWriter {
+	if defaultType == "" {
+		defaultType = "about:blank"
+	}
+	return func(httpRes http.ResponseWriter, httpReq *http.Request, code int, err error) {
+		problem := ProblemDetails{
+			Type:      defaultType,
+			Title:     http.StatusText(code),
+			Status:    code,
+			Detail:    err.Error(),
+			Instance:  httpReq.URL.Path,
+			ErrorCode: ErrorCodeOf(err, code),
+		}
+		for e := err; e != nil; e = parentError(e) {
+			if typer, ok := e.(ProblemTyper); ok && typer.ProblemType() != "" {
+				problem.Type = typer.ProblemType()
+				break
+			}
+		}
+		problem.Details = errorDetails(err)
+		jsonBody, jsonErr := json.Marshal(problem)
+		if jsonErr != nil {
+			log.Errorf("failed to encode problem body: %+v", jsonErr)
+			problem.Details = nil //details could not be encoded
+			jsonBody, _ = json.Marshal(problem)
+		}
+		jsonBody = append(jsonBody, '\n')
+		httpRes.Header().Set("Content-Type", problemMediaType)
+		httpRes.Header().Set("X-Content-Type-Options", "nosniff")
+		httpRes.Header().Set("Content-Length", strconv.Itoa(len(jsonBody)))
+		httpRes.WriteHeader(code)
+		if _, err := httpRes.Write(jsonBody); err != nil {
+			log.Errorf("failed to write problem body: %+v", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+// outOfStockErr has its own problem type
+type outOfStockErr struct{}
+
+func (outOfStockErr) Error() string       { return "out of stock" }
+func (outOfStockErr) Code() int           { return http.StatusConflict }
+func (outOfStockErr) ProblemType() string { return "https://example.com/problems/out-of-stock" }
+
+func TestProblemDetails(t *testing.T) {
+	h := testHandler(t, Config{ProblemDetails: true, ProblemType: "https://example.com/problems/error"}, testMS{
+		"buy": testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, outOfStockErr{} }},
+	})
+	problem := func(w *httptest.ResponseRecorder) ProblemDetails {
+		t.Helper()
+		var p ProblemDetails
+		if w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Fatalf("got %q", w.Header().Get("Content-Type"))
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("invalid problem %s: %+v", w.Body, err)
+		}
+		return p
+	}
+
+	w := do(h, http.MethodPost, "/buy", "")
+	p := problem(w)
+	if w.Code != http.StatusConflict || p.Type != "https://example.com/problems/out-of-stock" || p.Title != "Conflict" ||
+		p.Status != http.StatusConflict || p.Detail == "" || p.Instance != "/buy" {
+		t.Fatalf("got %d %+v", w.Code, p)
+	}
+	w = do(h, http.MethodPost, "/sell", "")
+	if p := problem(w); w.Code != http.StatusNotFound || p.Type != "https://example.com/problems/error" ||
+		p.Status != http.StatusNotFound || p.ErrorCode != ErrorCodeUnknownOperation || p.Instance != "/sell" {
+		t.Fatalf("unknown operation got %d %+v", w.Code, p)
+	}
+	if p := problem(do(testHandler(t, Config{ProblemDetails: true}, testMS{}), http.MethodPost, "/sell", "")); p.Type != "about:blank" {
+		t.Fatalf("default type got %+v", p)
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-msvc/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+const protobufMediaType = "application/x-protobuf"
+
+// ErrEncoderNotApplicable is returned by an Encoder that cannot encode a result,
+// the response is then encoded as JSON instead
+var ErrEncoderNotApplicable = errors.Errorf("encoder not applicable")
+
+// DecodeProtobuf decodes a protobuf body into the request, which must be a proto.Message
+// e.g. a request type generated by protoc-gen-go, as a pointer implements proto.Message
+func DecodeProtobuf(httpReq *http.Request, reqPtr interface{}) error {
+	msg, ok := reqPtr.(proto.Message)
+	if !ok {
+		return errors.Errorc(http.StatusUnsupportedMediaType, fmt.Sprintf("%T is not a protobuf message", reqPtr))
+	}
+	body, err := io.ReadAll(httpReq.Body)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return io.EOF
+	}
+	return proto.Unmarshal(body, msg)
+}
+
+// EncodeProtobuf encodes a proto.Message result
+// other results return ErrEncoderNotApplicable so that they are sent as JSON
+func EncodeProtobuf(res interface{}) ([]byte, error) {
+	msg, ok := res.(proto.Message)
+	if !ok {
+		return nil, ErrEncoderNotApplicable
+	}
+	return proto.Marshal(msg)
+}
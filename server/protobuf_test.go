@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/ms"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtobuf(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"greet": testOper{reqType: reflect.TypeOf(wrapperspb.StringValue{}), handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			return wrapperspb.String("hello " + req.(wrapperspb.StringValue).Value), nil
+		}},
+		"count": testOper{handle: result(map[string]int{"n": 1})},
+		"typed": testOper{reqType: reflect.TypeOf(contactReq{}), handle: echo},
+	})
+	body, _ := proto.Marshal(wrapperspb.String("bob"))
+	httpReq := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(string(body)))
+	httpReq.Header.Set("Content-Type", protobufMediaType)
+	httpReq.Header.Set("Accept", protobufMediaType)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httpReq)
+	var greeting wrapperspb.StringValue
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != protobufMediaType {
+		t.Fatalf("protobuf got %d %v", w.Code, w.Header())
+	}
+	if err := proto.Unmarshal(w.Body.Bytes(), &greeting); err != nil || greeting.GetValue() != "hello bob" {
+		t.Fatalf("protobuf result %q: %v", greeting.GetValue(), err)
+	}
+	//JSON request to a protobuf operation, with a JSON result
+	if w := do(h, http.MethodPost, "/greet", `{"value":"ann"}`); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("JSON got %d %s %v", w.Code, w.Body, w.Header())
+	}
+	//results that are not protobuf messages fall back to JSON
+	if w := do(h, http.MethodGet, "/count", "", "Accept", protobufMediaType); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != `{"n":1}` {
+		t.Fatalf("fallback got %d %s %v", w.Code, w.Body, w.Header())
+	}
+	if w := do(h, http.MethodPost, "/typed", string(body), "Content-Type", protobufMediaType); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("protobuf body for a struct got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-msvc/errors"
+)
+
+// RateLimitConfig limits requests with a token bucket per client
+type RateLimitConfig struct {
+	// Rate is the number of requests per second added to each bucket
+	Rate float64
+	// Burst is the bucket size, i.e. requests allowed at once
+	Burst int
+	// PerOperation keeps a separate bucket for each operation of a client
+	PerOperation bool
+	// Key identifies the client, defaults to the client IP
+	Key func(httpReq *http.Request) string `json:"-"`
+}
+
+func (c RateLimitConfig) Validate() error {
+	if c.Rate <= 0 {
+		return errors.Errorf("rate:%v must be positive", c.Rate)
+	}
+	if c.Burst < 1 {
+		return errors.Errorf("burst:%d must be at least 1", c.Burst)
+	}
+	return nil
+}
+
+type rateLimiter struct {
+	config    RateLimitConfig
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(c RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:    c,
+		buckets:   map[string]*bucket{},
+		lastPrune: time.Now(),
+	}
+}
+
+// allow takes a token for the request from the client with the given IP
+// when none is available it returns the time until the next token
+func (l *rateLimiter) allow(httpReq *http.Request, ip string, operName string, now time.Time) (bool, time.Duration) {
+	key := ip
+	if l.config.Key != nil {
+		key = l.config.Key(httpReq)
+	}
+	if l.config.PerOperation {
+		key += "|" + operName
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.prune(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.config.Burst), b.tokens+now.Sub(b.last).Seconds()*l.config.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.config.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune removes buckets that have refilled completely, as they are equivalent to new buckets
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	refill := time.Duration(float64(l.config.Burst) / l.config.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > refill {
+			delete(l.buckets, key)
+		}
+	}
+}
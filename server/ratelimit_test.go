@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	h := testHandler(t, Config{RateLimit: &RateLimitConfig{Rate: 20, Burst: 2}}, testMS{"get": testOper{handle: result("ok")}})
+	for i := 0; i < 2; i++ {
+		if w := do(h, http.MethodGet, "/get", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d got %d", i, w.Code)
+		}
+	}
+	w := do(h, http.MethodGet, "/get", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("exhausted bucket got %d Retry-After:%q", w.Code, w.Header().Get("Retry-After"))
+	}
+	time.Sleep(60 * time.Millisecond)
+	if w := do(h, http.MethodGet, "/get", ""); w.Code != http.StatusOK {
+		t.Fatalf("after refill got %d", w.Code)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	l := newRateLimiter(RateLimitConfig{Rate: 1, Burst: 1, PerOperation: true})
+	httpReq := httptest.NewRequest(http.MethodGet, "/", nil)
+	now := time.Now()
+	if ok, _ := l.allow(httpReq, "10.0.0.1", "a", now); !ok {
+		t.Fatal("first request rejected")
+	}
+	if ok, wait := l.allow(httpReq, "10.0.0.1", "a", now); ok || wait != time.Second {
+		t.Fatalf("second request got %v, wait %v", ok, wait)
+	}
+	if ok, _ := l.allow(httpReq, "10.0.0.1", "b", now); !ok {
+		t.Fatal("other operation shares the bucket")
+	}
+	if ok, _ := l.allow(httpReq, "10.0.0.2", "a", now); !ok {
+		t.Fatal("other client shares the bucket")
+	}
+	if ok, _ := l.allow(httpReq, "10.0.0.1", "a", now.Add(time.Second)); !ok {
+		t.Fatal("bucket did not refill")
+	}
+	if err := (RateLimitConfig{Rate: 0, Burst: 1}).Validate(); err == nil {
+		t.Fatal("zero rate accepted")
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxLoggedBodyBytes limits the body size kept for logging,
+// larger bodies cannot be redacted and are logged as a size only
+const maxLoggedBodyBytes = 64 << 10
+
+// defaultRedactFields are masked when Config.RedactFields is nil
+var defaultRedactFields = []string{"password", "secret", "token", "accessToken", "refreshToken", "apiKey"}
+
+// redacted replaces the values of redacted fields
+const redacted = "****"
+
+// bodyCapture keeps the first maxLoggedBodyBytes written to it
+type bodyCapture struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (c *bodyCapture) Write(p []byte) (int, error) {
+	if room := maxLoggedBodyBytes - c.Len(); len(p) > room {
+		c.truncated = true
+		c.Buffer.Write(p[:room])
+		return len(p), nil
+	}
+	return c.Buffer.Write(p)
+}
+
+// redactor masks the values of fields with json names in a set,
+// compared case-insensitively, anywhere in a logged JSON body
+type redactor map[string]bool
+
+func newRedactor(fields []string) redactor {
+	r := redactor{}
+	for _, field := range fields {
+		r[strings.ToLower(field)] = true
+	}
+	return r
+}
+
+// body returns body with redacted fields masked, for logging
+// bodies that are not complete JSON documents are summarised, as they cannot be redacted
+func (r redactor) body(body []byte, truncated bool) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if truncated {
+		return r.summary(fmt.Sprintf("%d+ bytes not logged", len(body)))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return r.summary(fmt.Sprintf("%d bytes non-JSON body not logged", len(body)))
+	}
+	logged, err := json.Marshal(r.walk(value))
+	if err != nil {
+		return r.summary(fmt.Sprintf("%d bytes not logged", len(body)))
+	}
+	return logged
+}
+
+func (r redactor) walk(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range v {
+			if r[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = r.walk(fieldValue)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = r.walk(v[i])
+		}
+	}
+	return value
+}
+
+func (r redactor) summary(message string) json.RawMessage {
+	summary, _ := json.Marshal("[" + message + "]")
+	return summary
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+func TestRedactedBodies(t *testing.T) {
+	var sink bytes.Buffer
+	h, rec := loggedHandler(t, Config{AccessLog: true, AccessLogWriter: &sink, LogBodies: true, RedactFields: []string{"password", "pin"}}, testMS{
+		"login": testOper{reqType: reflect.TypeOf(map[string]interface{}{}), handle: echo},
+		"fail": testOper{reqType: reflect.TypeOf(map[string]interface{}{}), handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorf("downstream failed")
+		}},
+	})
+	body := `{"user":"bob","Password":"secret-1","cards":[{"pin":"1234","last4":"9876"}]}`
+	if w := do(h, http.MethodPost, "/login", body); w.Code != http.StatusOK {
+		t.Fatalf("login got %d %s", w.Code, w.Body)
+	}
+	var entry accessLogEntry
+	if err := json.Unmarshal(bytes.TrimSpace(sink.Bytes()), &entry); err != nil {
+		t.Fatalf("invalid line %s: %+v", sink.Bytes(), err)
+	}
+	for _, logged := range []json.RawMessage{entry.RequestBody, entry.ResponseBody} {
+		s := string(logged)
+		if strings.Contains(s, "secret-1") || strings.Contains(s, "1234") || !strings.Contains(s, `"bob"`) || !strings.Contains(s, `"9876"`) || strings.Count(s, redacted) != 2 {
+			t.Fatalf("logged body %s", s)
+		}
+	}
+
+	if w := do(h, http.MethodPost, "/fail", body); w.Code != http.StatusInternalServerError {
+		t.Fatalf("fail got %d", w.Code)
+	}
+	if rec.contains("secret-1") || rec.contains("1234") || !rec.contains(`"bob"`) {
+		t.Fatalf("error log %q", rec.lines)
+	}
+}
+
+func TestRedactNonJSON(t *testing.T) {
+	r := newRedactor(defaultRedactFields)
+	if logged := string(r.body([]byte("password=secret"), false)); strings.Contains(logged, "secret") {
+		t.Fatalf("non-JSON body logged as %s", logged)
+	}
+	if logged := string(r.body([]byte(`{"token":"t1"`), true)); strings.Contains(logged, "t1") {
+		t.Fatalf("truncated body logged as %s", logged)
+	}
+	if logged := r.body(nil, false); logged != nil {
+		t.Fatalf("empty body logged as %s", logged)
+	}
+}
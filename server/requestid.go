@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// RequestIDHeader is read from the request and echoed in the response
+const RequestIDHeader = "X-Request-ID"
+
+// CausationIDHeader carries the ID of the request that caused this one
+// it is read and echoed with Config.CausationIDs
+const CausationIDHeader = "X-Causation-ID"
+
+// maxRequestIDLen limits accepted incoming request IDs, longer ones are replaced
+const maxRequestIDLen = 128
+
+// RequestID returns the id of the HTTP request being handled
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// CausationID returns the id of the request that caused the HTTP request being handled,
+// only set with Config.CausationIDs when the caller sent an ID
+func CausationID(ctx context.Context) string {
+	id, _ := ctx.Value(causationIDKey).(string)
+	return id
+}
+
+// PropagateRequestIDs sets the headers of a downstream request made while handling ctx
+// so that the downstream server records this request as its cause
+func PropagateRequestIDs(ctx context.Context, header http.Header) {
+	if id := RequestID(ctx); id != "" {
+		header.Set(RequestIDHeader, id)
+		header.Set(CausationIDHeader, id)
+	}
+}
+
+// requestIDs returns the id of the request and the id of its cause
+// with causation the request always gets a new id, its cause is the incoming
+// X-Causation-ID, else the incoming X-Request-ID, i.e. the request id of the caller
+func requestIDs(header http.Header, causation bool) (string, string) {
+	if !causation {
+		return requestIDFrom(header.Get(RequestIDHeader)), ""
+	}
+	for _, name := range []string{CausationIDHeader, RequestIDHeader} {
+		if id := header.Get(name); id != "" && validRequestID(id) {
+			return newUUID(), id
+		}
+	}
+	return newUUID(), ""
+}
+
+// requestIDFrom returns a valid incoming id or generates a new one
+func requestIDFrom(id string) string {
+	if id == "" || !validRequestID(id) {
+		return newUUID()
+	}
+	return id
+}
+
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false //prevent log injection
+		}
+	}
+	return true
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %+v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// requestLogger prefixes log lines with the request id
+type requestLogger struct {
+	id  string
+	log leveledLogger
+}
+
+func (l requestLogger) Debugf(format string, args ...interface{}) {
+	l.log.Debugf("[%s] "+format, append([]interface{}{l.id}, args...)...)
+}
+
+func (l requestLogger) Infof(format string, args ...interface{}) {
+	l.log.Infof("[%s] "+format, append([]interface{}{l.id}, args...)...)
+}
+
+func (l requestLogger) Errorf(format string, args ...interface{}) {
+	l.log.Errorf("[%s] "+format, append([]interface{}{l.id}, args...)...)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{"id": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		return RequestID(ctx), nil
+	}}})
+
+	w := do(h, http.MethodGet, "/id", "", RequestIDHeader, "abc-123")
+	if w.Header().Get(RequestIDHeader) != "abc-123" || w.Body.String() != `"abc-123"` {
+		t.Fatalf("got %q %s", w.Header().Get(RequestIDHeader), w.Body)
+	}
+
+	w = do(h, http.MethodGet, "/id", "")
+	id := w.Header().Get(RequestIDHeader)
+	if !uuidPattern.MatchString(id) || w.Body.String() != `"`+id+`"` {
+		t.Fatalf("generated %q, handler got %s", id, w.Body)
+	}
+
+	if w := do(h, http.MethodGet, "/id", "", RequestIDHeader, "bad id\n"); w.Header().Get(RequestIDHeader) == "bad id\n" {
+		t.Fatal("invalid request id echoed")
+	}
+}
+
+// hop are the ids a server in a chain of calls saw
+type hop struct {
+	Request, Causation string
+}
+
+// chainOper returns the ids it saw followed by those of the downstream calls
+func chainOper(t *testing.T, downstream http.Handler) testOper {
+	return testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		hops := []hop{{RequestID(ctx), CausationID(ctx)}}
+		if downstream != nil {
+			httpReq := httptest.NewRequest(http.MethodGet, "/chain", nil)
+			PropagateRequestIDs(ctx, httpReq.Header)
+			httpRes := httptest.NewRecorder()
+			downstream.ServeHTTP(httpRes, httpReq)
+			var downstreamHops []hop
+			if err := json.Unmarshal(httpRes.Body.Bytes(), &downstreamHops); err != nil {
+				t.Fatalf("invalid downstream response %s: %+v", httpRes.Body, err)
+			}
+			hops = append(hops, downstreamHops...)
+		}
+		return hops, nil
+	}}
+}
+
+func TestCausationIDs(t *testing.T) {
+	c := Config{CausationIDs: true}
+	last := testHandler(t, c, testMS{"chain": chainOper(t, nil)})
+	middle := testHandler(t, c, testMS{"chain": chainOper(t, last)})
+	first := testHandler(t, c, testMS{"chain": chainOper(t, middle)})
+
+	w := do(first, http.MethodGet, "/chain", "", RequestIDHeader, "client-1")
+	var hops []hop
+	if err := json.Unmarshal(w.Body.Bytes(), &hops); err != nil || len(hops) != 3 {
+		t.Fatalf("got %s: %v", w.Body, err)
+	}
+	if !uuidPattern.MatchString(hops[0].Request) || hops[0].Causation != "client-1" ||
+		w.Header().Get(RequestIDHeader) != hops[0].Request || w.Header().Get(CausationIDHeader) != "client-1" {
+		t.Fatalf("first hop %+v with %v", hops[0], w.Header())
+	}
+	for i := 1; i < len(hops); i++ {
+		if hops[i].Causation != hops[i-1].Request || hops[i].Request == hops[i-1].Request || !uuidPattern.MatchString(hops[i].Request) {
+			t.Fatalf("hop %d %+v after %+v", i, hops[i], hops[i-1])
+		}
+	}
+
+	//an explicit causation id takes precedence over the caller request id
+	if w := do(first, http.MethodGet, "/chain", "", RequestIDHeader, "client-2", CausationIDHeader, "job-7"); w.Header().Get(CausationIDHeader) != "job-7" {
+		t.Fatalf("got causation %q", w.Header().Get(CausationIDHeader))
+	}
+	//without causation ids the incoming request id is kept
+	w = do(testHandler(t, Config{}, testMS{"chain": chainOper(t, nil)}), http.MethodGet, "/chain", "", RequestIDHeader, "client-1")
+	if w.Header().Get(RequestIDHeader) != "client-1" || w.Header().Get(CausationIDHeader) != "" || w.Body.String() != `[{"Request":"client-1","Causation":""}]` {
+		t.Fatalf("got %v %s", w.Header(), w.Body)
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+// PathOper is optionally implemented by an operation to be reachable on a
+// templated path such as "/users/{id}/orders/{orderId}" in addition to the
+// default "/<operName>". Captured segments are bound into request struct
+// fields tagged with `path:"<name>"`.
+type PathOper interface {
+	Path() string
+}
+
+type route struct {
+	operName string
+	segments []string //static segment, or "{name}" for a parameter
+}
+
+func (r route) String() string {
+	return "/" + strings.Join(r.segments, "/")
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+func newRoute(operName string, path string) (route, error) {
+	if !strings.HasPrefix(path, "/") {
+		return route{}, errors.Errorf("path %q does not start with /", path)
+	}
+	r := route{operName: operName, segments: strings.Split(path[1:], "/")}
+	names := map[string]bool{}
+	for _, segment := range r.segments {
+		if segment == "" {
+			return route{}, errors.Errorf("path %q has an empty segment", path)
+		}
+		if isParam(segment) {
+			name := segment[1 : len(segment)-1]
+			if name == "" {
+				return route{}, errors.Errorf("path %q has an unnamed parameter", path)
+			}
+			if names[name] {
+				return route{}, errors.Errorf("path %q has duplicate parameter %s", path, name)
+			}
+			names[name] = true
+		}
+	}
+	return r, nil
+}
+
+// routeLess orders routes so that, segment by segment from the left,
+// a static segment is tried before a parameter
+func routeLess(a, b route) bool {
+	if len(a.segments) != len(b.segments) {
+		return len(a.segments) < len(b.segments)
+	}
+	for i := range a.segments {
+		ap, bp := isParam(a.segments[i]), isParam(b.segments[i])
+		if ap != bp {
+			return !ap
+		}
+		if !ap && a.segments[i] != b.segments[i] {
+			return a.segments[i] < b.segments[i]
+		}
+	}
+	return false
+}
+
+// routesFor builds the sorted route table for operations implementing PathOper
+// and fails if two operations declare paths that match the same requests
+func routesFor(svc ms.MicroService) ([]route, error) {
+	routes := []route{}
+	for _, operName := range svc.OperNames() {
+		oper, ok := svc.Oper(operName)
+		if !ok {
+			continue
+		}
+		pathOper, ok := oper.(PathOper)
+		if !ok {
+			continue
+		}
+		r, err := newRoute(operName, pathOper.Path())
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid path for operation %s", operName)
+		}
+		routes = append(routes, r)
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routeLess(routes[i], routes[j]) })
+	for i := 1; i < len(routes); i++ {
+		if !routeLess(routes[i-1], routes[i]) {
+			return nil, errors.Errorf("operations %s and %s have conflicting paths %s and %s",
+				routes[i-1].operName, routes[i].operName, routes[i-1], routes[i])
+		}
+	}
+	return routes, nil
+}
+
+// checkPrefixRoutes fails when an operation path could match a path under prefix,
+// as it would never be reached while prefix is served by another handler
+func checkPrefixRoutes(routes []route, prefix string) error {
+	prefixSegments := strings.Split(strings.Trim(prefix, "/"), "/")
+	for _, r := range routes {
+		if len(r.segments) <= len(prefixSegments) {
+			continue
+		}
+		shadowed := true
+		for i, segment := range prefixSegments {
+			if r.segments[i] != segment && !isParam(r.segments[i]) {
+				shadowed = false
+				break
+			}
+		}
+		if shadowed {
+			return errors.Errorf("operation %s path %s conflicts with %s", r.operName, r, prefix)
+		}
+	}
+	return nil
+}
+
+// matchRoute returns the operation name and captured parameters of the first
+// route matching the URL path, or "" if none matches.
+// Segments are split before unescaping so an encoded "/" stays inside its segment.
+// Matching is strict: a trailing slash adds an empty segment that matches nothing.
+func matchRoute(routes []route, u *url.URL) (string, map[string]string, error) {
+	if len(routes) == 0 {
+		return "", nil, nil
+	}
+	escaped := strings.Split(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+	segments := make([]string, len(escaped))
+	for i, e := range escaped {
+		var err error
+		if segments[i], err = url.PathUnescape(e); err != nil {
+			return "", nil, errors.Wrapf(err, "invalid path segment %q", e)
+		}
+	}
+	for _, r := range routes {
+		if len(r.segments) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		matched := true
+		for i, rs := range r.segments {
+			if isParam(rs) {
+				if segments[i] == "" {
+					matched = false
+					break
+				}
+				params[rs[1:len(rs)-1]] = segments[i]
+			} else if rs != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return r.operName, params, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// trailing slash policies for Config.TrailingSlash
+const (
+	TrailingSlashStrict   = ""         //no normalization, so the path does not match an operation
+	TrailingSlashStrip    = "strip"    //ignore trailing slashes
+	TrailingSlashRedirect = "redirect" //redirect to the path without trailing slashes
+	TrailingSlashReject   = "reject"   //400 Bad Request
+)
+
+func hasTrailingSlash(u *url.URL) bool {
+	return len(u.Path) > 1 && strings.HasSuffix(u.Path, "/")
+}
+
+func withoutTrailingSlash(u *url.URL) *url.URL {
+	stripped := *u
+	stripped.Path = "/" + strings.Trim(u.Path, "/")
+	if u.RawPath != "" {
+		stripped.RawPath = "/" + strings.Trim(u.RawPath, "/")
+	}
+	return &stripped
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// routeOper is also reachable on a templated path
+type routeOper struct {
+	testOper
+	path string
+}
+
+func (o routeOper) Path() string { return o.path }
+
+type orderReq struct {
+	UserID  string `path:"id"`
+	OrderID int    `path:"orderId"`
+}
+
+func TestPathTemplates(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"getOrder": routeOper{testOper{reqType: reflect.TypeOf(orderReq{}), handle: echo}, "/users/{id}/orders/{orderId}"},
+		"getUser":  routeOper{testOper{reqType: reflect.TypeOf(orderReq{}), handle: echo}, "/users/{id}"},
+		"me":       routeOper{testOper{handle: result("me")}, "/users/me"},
+	})
+	for target, want := range map[string]string{
+		"/users/u1/orders/42": `{"UserID":"u1","OrderID":42}`,
+		"/users/u1":           `{"UserID":"u1","OrderID":0}`,
+		"/users/me":           `"me"`, //static segments win over parameters
+		"/users/a%2Fb":        `{"UserID":"a/b","OrderID":0}`,
+		"/getUser":            `{"UserID":"","OrderID":0}`,
+	} {
+		if w := do(h, http.MethodGet, target, ""); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%s got %d %s, expected %s", target, w.Code, w.Body, want)
+		}
+	}
+	for _, target := range []string{"/users/u1/", "/users/u1/orders/x", "/users/u1/orders"} {
+		if w := do(h, http.MethodGet, target, ""); w.Code == http.StatusOK {
+			t.Errorf("%s matched: %s", target, w.Body)
+		}
+	}
+}
+
+func TestPathOverridesBody(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"putOrder": routeOper{testOper{reqType: reflect.TypeOf(orderReq{}), handle: echo}, "/users/{id}/orders/{orderId}"},
+	})
+	//encoding/json matches the field names case-insensitively
+	w := do(h, http.MethodPut, "/users/u1/orders/42", `{"userid":"attacker","orderId":7}`)
+	if want := `{"UserID":"u1","OrderID":42}`; w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("got %d %s, expected %s", w.Code, w.Body, want)
+	}
+}
+
+func TestPathConflicts(t *testing.T) {
+	_, err := Config{}.Handler(testMS{
+		"a": routeOper{testOper{handle: echo}, "/items/{id}"},
+		"b": routeOper{testOper{handle: echo}, "/items/{name}"},
+	})
+	if err == nil {
+		t.Fatal("conflicting templates accepted")
+	}
+	if _, err := (Config{}).Handler(testMS{"a": routeOper{testOper{handle: echo}, "/items/{id}/{id}"}}); err == nil {
+		t.Fatal("duplicate parameter accepted")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	svc := testMS{"secretOperation": testOper{handle: result("ok")}}
+	w := do(testHandler(t, Config{}, svc), http.MethodGet, "/missing", "")
+	if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "secretOperation") {
+		t.Fatalf("default got %d %s", w.Code, w.Body)
+	}
+	if info := errorBody(t, w.Body.Bytes()); info.ErrorCode != ErrorCodeUnknownOperation {
+		t.Fatalf("default got %+v", info)
+	}
+	if w := do(testHandler(t, Config{Debug: true}, svc), http.MethodGet, "/missing", ""); !strings.Contains(w.Body.String(), "secretOperation") {
+		t.Fatalf("debug got %d %s", w.Code, w.Body)
+	}
+	custom := http.HandlerFunc(func(httpRes http.ResponseWriter, httpReq *http.Request) {
+		httpRes.WriteHeader(http.StatusTeapot)
+		httpRes.Write([]byte("no " + httpReq.URL.Path))
+	})
+	if w := do(testHandler(t, Config{NotFoundHandler: custom}, svc), http.MethodGet, "/missing", ""); w.Code != http.StatusTeapot || w.Body.String() != "no /missing" {
+		t.Fatalf("custom got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	svc := testMS{"createUser": testOper{handle: result("created")}}
+	if w := do(testHandler(t, Config{}, svc), http.MethodPost, "/CreateUser", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("case sensitive got %d", w.Code)
+	}
+	h := testHandler(t, Config{CaseInsensitivePaths: true}, svc)
+	for _, target := range []string{"/createUser", "/CreateUser", "/CREATEUSER"} {
+		if w := do(h, http.MethodPost, target, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s got %d", target, w.Code)
+		}
+	}
+	if w := do(h, http.MethodPost, "/createUsers", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("other name got %d", w.Code)
+	}
+
+	//exact matches are preferred and names that differ only by case are rejected
+	both := testMS{"user": testOper{handle: result("lower")}, "User": testOper{handle: result("upper")}}
+	if w := do(testHandler(t, Config{}, both), http.MethodGet, "/User", ""); w.Body.String() != `"upper"` {
+		t.Fatalf("case sensitive got %s", w.Body)
+	}
+	if _, err := (Config{Addr: "localhost", CaseInsensitivePaths: true}).Handler(both); err == nil {
+		t.Fatal("names differing by case accepted")
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	svc := testMS{"getUser": testOper{handle: result("ok")}}
+	if w := do(testHandler(t, Config{}, svc), http.MethodGet, "/getUser/", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("strict got %d", w.Code)
+	}
+	if w := do(testHandler(t, Config{TrailingSlash: TrailingSlashStrip}, svc), http.MethodGet, "/getUser/", ""); w.Code != http.StatusOK {
+		t.Fatalf("strip got %d", w.Code)
+	}
+	h := testHandler(t, Config{TrailingSlash: TrailingSlashRedirect}, svc)
+	if w := do(h, http.MethodGet, "/getUser/?id=1", ""); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/getUser?id=1" {
+		t.Fatalf("redirect got %d %v", w.Code, w.Header())
+	}
+	if w := do(h, http.MethodPost, "/getUser/", "{}"); w.Code != http.StatusPermanentRedirect {
+		t.Fatalf("POST redirect got %d", w.Code)
+	}
+	if w := do(testHandler(t, Config{TrailingSlash: TrailingSlashReject}, svc), http.MethodGet, "/getUser/", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("reject got %d", w.Code)
+	}
+	if err := (Config{Addr: "localhost", TrailingSlash: "ignore"}).Validate(); err == nil {
+		t.Fatal("unknown policy accepted")
+	}
+}
+
+func TestOperationNameResolver(t *testing.T) {
+	h := testHandler(t, Config{OperationNameResolver: func(httpReq *http.Request) (string, error) {
+		parts := strings.Split(strings.Trim(httpReq.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[1] != "v1" {
+			return "", fmt.Errorf("expecting /<resource>/v1/<action>")
+		}
+		return parts[0] + "." + parts[2], nil
+	}}, testMS{
+		"users.get": testOper{handle: result("user")},
+		"item":      routeOper{testOper{handle: result("item")}, "/items/{id}"},
+	})
+	for target, want := range map[string]int{
+		"/users/v1/get":  http.StatusOK,
+		"/users/v1/list": http.StatusNotFound,
+		"/users/get":     http.StatusBadRequest,
+		"/users.get":     http.StatusBadRequest, //the default parsing is replaced
+		"/items/1":       http.StatusOK,         //templates are matched first
+	} {
+		if w := do(h, http.MethodGet, target, ""); w.Code != want {
+			t.Errorf("%s got %d %s, want %d", target, w.Code, w.Body, want)
+		}
+	}
+	if w := do(h, http.MethodGet, "/users/v1/get", ""); w.Body.String() != `"user"` {
+		t.Fatalf("got %s", w.Body)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaOper is optionally implemented by an operation to validate JSON bodies
+// against a JSON Schema document, an empty schema is not validated
+// an invalid request body is rejected with 400, an invalid response is
+// only checked with Config.ValidateResponses and answered with 500
+type SchemaOper interface {
+	RequestSchema() string
+	ResponseSchema() string
+}
+
+// operSchemas are the compiled schemas of an operation, nil when not defined
+type operSchemas struct {
+	req *jsonschema.Schema
+	res *jsonschema.Schema
+}
+
+// compileSchemas compiles the schemas of all operations in svc once
+// so that requests are validated without parsing the schema again
+func compileSchemas(svc ms.MicroService) (map[string]operSchemas, error) {
+	schemas := map[string]operSchemas{}
+	for _, operName := range svc.OperNames() {
+		oper, _ := svc.Oper(operName)
+		schemaOper, ok := oper.(SchemaOper)
+		if !ok {
+			continue
+		}
+		var compiled operSchemas
+		var err error
+		if compiled.req, err = compileSchema(operName+"/request.json", schemaOper.RequestSchema()); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s request schema", operName)
+		}
+		if compiled.res, err = compileSchema(operName+"/response.json", schemaOper.ResponseSchema()); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s response schema", operName)
+		}
+		if compiled.req != nil || compiled.res != nil {
+			schemas[operName] = compiled
+		}
+	}
+	return schemas, nil
+}
+
+func compileSchema(url string, schema string) (*jsonschema.Schema, error) {
+	if schema == "" {
+		return nil, nil
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, strings.NewReader(schema)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// validateSchema checks a JSON document against the schema
+// schema violations are returned as a ValidationError with one field per violation
+func validateSchema(schema *jsonschema.Schema, body []byte) error {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() //keep number precision for the schema limits
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+	err := schema.Validate(doc)
+	var schemaErr *jsonschema.ValidationError
+	if !stderrors.As(err, &schemaErr) {
+		return err
+	}
+	fields := []FieldError{}
+	for _, cause := range schemaLeaves(schemaErr) {
+		field := strings.TrimPrefix(cause.InstanceLocation, "/")
+		fields = append(fields, FieldError{
+			Field:   field,
+			Rule:    "schema",
+			Param:   cause.KeywordLocation,
+			Message: fmt.Sprintf("%s: %s", schemaFieldName(field), cause.Message),
+		})
+	}
+	return ValidationError{Fields: fields}
+}
+
+// schemaLeaves returns the innermost causes that describe the actual violations
+func schemaLeaves(e *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(e.Causes) == 0 {
+		return []*jsonschema.ValidationError{e}
+	}
+	leaves := []*jsonschema.ValidationError{}
+	for _, cause := range e.Causes {
+		leaves = append(leaves, schemaLeaves(cause)...)
+	}
+	return leaves
+}
+
+func schemaFieldName(field string) string {
+	if field == "" {
+		return "body"
+	}
+	return strings.ReplaceAll(field, "/", ".")
+}
+
+// isJSONRequest is true when the body would be decoded as JSON
+func isJSONRequest(httpReq *http.Request) bool {
+	contentType := httpReq.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
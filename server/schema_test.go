@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// schemaOper validates its bodies against JSON schemas
+type schemaOper struct {
+	testOper
+	req, res string
+}
+
+func (o schemaOper) RequestSchema() string  { return o.req }
+func (o schemaOper) ResponseSchema() string { return o.res }
+
+const contactSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string", "minLength": 2},
+		"email": {"type": "string", "pattern": "@"}
+	}
+}`
+
+func TestSchema(t *testing.T) {
+	h, rec := loggedHandler(t, Config{ValidateResponses: true}, testMS{
+		"create": schemaOper{testOper{reqType: reflect.TypeOf(contactReq{}), handle: echo}, contactSchema, ""},
+		"get":    schemaOper{testOper{handle: result(map[string]int{"name": 1})}, "", contactSchema},
+	})
+	if w := do(h, http.MethodPost, "/create", `{"name":"bob","email":"bob@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("valid request got %d %s", w.Code, w.Body)
+	}
+	w := do(h, http.MethodPost, "/create", `{"name":"b","email":"bob"}`)
+	info := errorBody(t, w.Body.Bytes())
+	if w.Code != http.StatusBadRequest || info.ErrorCode != ErrorCodeValidationFailed {
+		t.Fatalf("invalid request got %d %s", w.Code, w.Body)
+	}
+	if fields, _ := info.Details.([]interface{}); len(fields) != 2 {
+		t.Fatalf("expected 2 field errors, got %v", info.Details)
+	}
+	if w := do(h, http.MethodPost, "/create", `{"email":"bob@example.com"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing required got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodGet, "/get", ""); w.Code != http.StatusInternalServerError || !rec.contains("response") {
+		t.Fatalf("invalid response got %d %s, log %q", w.Code, w.Body, rec.lines)
+	}
+}
+
+func TestInvalidSchema(t *testing.T) {
+	svc := testMS{"create": schemaOper{testOper{handle: echo}, `{"type": 1}`, ""}}
+	if _, err := (Config{Addr: "localhost"}).Handler(svc); err == nil {
+		t.Fatal("invalid schema accepted")
+	}
+}
@@ -1,126 +1,1539 @@
 package server
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"os"
 	"reflect"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-msvc/errors"
 	"github.com/go-msvc/logger"
 	"github.com/go-msvc/ms"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/sync/singleflight"
 )
 
-var log = logger.New().WithLevel(logger.LevelDebug)
+// log is used where no server is available, servers log at Config.LogLevel
+var log = logger.New().WithLevel(logger.LevelInfo)
+
+// defaultMaxBodyBytes is used when Config.MaxBodyBytes is not set
+const defaultMaxBodyBytes = 1 << 20
 
 //implements github.com/go-msvc/ms.Server using an HTTP interface
 
 type Config struct {
 	Addr string
-	Port int
+	Port int //0 lets the OS choose a port, see OnListen and Addr()
+
+	// OnListen is called with the bound address once Serve() is listening
+	OnListen func(addr net.Addr) `json:"-"`
+
+	// AdminPort serves the health, ready, metrics and pprof endpoints over plain HTTP
+	// on this port of Addr instead of Port, which then only serves operations
+	AdminPort int
+
+	// UnixSocket is the path of a Unix domain socket to serve on instead of Addr and Port
+	UnixSocket string
+
+	// Timeouts applied to the underlying http.Server, zero means no timeout
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// DisableKeepAlives closes each connection after one request, e.g. behind a load balancer
+	// that pools its own connections, IdleTimeout cannot be set as connections are never idle
+	DisableKeepAlives bool
+
+	// TCPKeepAlive is the idle time before keep-alive probes on accepted TCP connections,
+	// zero uses the net/http default of 15s and a negative value disables probes
+	TCPKeepAlive time.Duration
+
+	// ShutdownTimeout limits the time Shutdown waits for in-flight requests
+	// before closing their connections, zero only uses the Shutdown context
+	ShutdownTimeout time.Duration
+
+	// MaxHeaderBytes limits the size of request headers, larger headers get 431
+	// zero uses the net/http default of 1 MiB
+	MaxHeaderBytes int
+
+	// MaxBodyBytes limits the request body size, larger bodies get 413
+	// zero uses the default of 1 MiB and a negative value removes the limit
+	MaxBodyBytes int64
+
+	// MaxPathLength rejects requests with a longer escaped URL path with 414, zero means no limit
+	MaxPathLength int
+
+	// HandlerTimeout limits the time an operation handler may run, zero means no limit
+	// the handler context is also canceled when the client disconnects
+	HandlerTimeout time.Duration
+
+	// MaxClientTimeout enables the Request-Timeout and X-Timeout-Ms request headers
+	// to shorten the handler deadline, capping the requested timeout at this value
+	MaxClientTimeout time.Duration
+
+	// SSEKeepAlive is the idle time after which a comment is sent on a server-sent event stream
+	// of an EventStreamOper, defaulting to 15s, WriteTimeout also limits the stream duration
+	SSEKeepAlive time.Duration
+
+	// PollTimeout is how long the handler of a LongPollOper may wait for data
+	// before the client gets 204 No Content, defaulting to 30s, keep it below WriteTimeout
+	PollTimeout time.Duration
+
+	// CheckResponseTypes logs an error when a result does not match the
+	// type declared by an operation implementing ResponseTyped, intended for debugging
+	CheckResponseTypes bool
+
+	// CertFile and KeyFile enable TLS when both are set
+	// ClientCAFile additionally requires clients to present a certificate signed by one of its CAs
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+
+	// H2C serves HTTP/2 over cleartext (prior knowledge or upgrade) in addition to HTTP/1.1
+	// it cannot be combined with TLS, which negotiates HTTP/2 itself
+	H2C bool
+
+	// Gzip compresses responses of at least GzipMinBytes (default 1024)
+	// when the client accepts gzip encoding, responses then have Vary: Accept-Encoding
+	Gzip         bool
+	GzipMinBytes int
+
+	// TrustedProxies lists CIDRs (or IPs) of proxies whose X-Forwarded-For and
+	// X-Real-IP headers are used to find the client IP, see ClientIP()
+	TrustedProxies []string
+
+	// CausationIDs gives every request a new X-Request-ID and takes the incoming
+	// X-Causation-ID, else the incoming X-Request-ID, as the id of the request that caused it
+	// see CausationID() and PropagateRequestIDs()
+	CausationIDs bool
+
+	// Idempotency replays responses for requests with a repeated Idempotency-Key header
+	Idempotency *IdempotencyConfig
+
+	// DedupRequests runs the operation once for identical concurrent requests
+	// with methods other than GET and HEAD, sharing the result and error with all of them
+	// requests are identical with the same operation, URL, body, response related headers,
+	// headers bound to request fields and principal of the Authenticator,
+	// other headers, e.g. those read by a ContextBuilder, are not compared
+	DedupRequests bool
+
+	// MaxConcurrentRequests rejects requests with 503 while this many are in progress,
+	// zero means unlimited, health and other built-in endpoints are not limited
+	MaxConcurrentRequests int
+
+	// RateLimit rejects requests with 429 when a client exceeds it
+	RateLimit *RateLimitConfig
+
+	// CircuitBreaker rejects requests of an operation with 503 and Retry-After
+	// without calling its handler after consecutive handler failures
+	CircuitBreaker *CircuitBreakerConfig
+
+	// CORS headers are only emitted when configured
+	CORS *CORSConfig
+
+	// HealthPath (default "/healthz") and ReadyPath (default "/readyz") are
+	// answered without operation routing, so they take precedence over operations of the same name
+	// readiness is reported by ReadyCheck, else by the micro-service if it implements ReadyChecker
+	HealthPath string
+	ReadyPath  string
+	ReadyCheck func() error `json:"-"`
+
+	// MetricsPath serves the metrics when the collector is an http.Handler, e.g. "/metrics"
+	// the path is checked before operation routing
+	// Metrics defaults to NewPrometheusMetrics() when MetricsPath is set
+	MetricsPath string
+	Metrics     MetricsCollector `json:"-"`
+
+	// Versions serves other versions of the API with their own micro-service
+	// the version is taken from a URL prefix, e.g. "/v2/<operName>", or with
+	// VersionHeader from the Accept-Version header
+	// requests without a version use the micro-service of the server unless RequireVersion is set
+	Versions       map[string]ms.MicroService `json:"-"`
+	VersionHeader  bool
+	RequireVersion bool
+
+	// Services mounts more micro-services under path prefixes such as "/billing",
+	// with operations on "/billing/<operName>", the longest prefix matching whole segments wins
+	// other paths use the micro-service of the server, and versions only apply to it
+	Services map[string]ms.MicroService `json:"-"`
+
+	// CaseInsensitivePaths also matches operation names after lowercasing,
+	// an exact match is still preferred
+	// it cannot be used when operation names differ only by case
+	CaseInsensitivePaths bool
+
+	// TrailingSlash is the policy for paths like "/getUser/":
+	// "" (strict, no match), "strip", "redirect" or "reject"
+	TrailingSlash string
+
+	// Aliases maps old operation names to current ones so that renamed operations
+	// stay reachable, an alias is only used when no operation has the old name
+	// responses to aliased requests get a "Deprecation: true" header
+	Aliases map[string]string
+
+	// OperationNameResolver returns the operation name of a request instead of the default
+	// "/<operName>" path, e.g. to map "/users/v1/get" to "users.get", after PathOper templates did not match
+	// URL.Path has the version or service prefix removed, and an error without a Code() gives 400
+	OperationNameResolver func(httpReq *http.Request) (string, error) `json:"-"`
+
+	// DryRunParam also accepts a query parameter, e.g. "dryRun", next to the
+	// X-Dry-Run header that validates a request without calling the handler
+	DryRunParam string
+
+	// Authenticator rejects requests with 401 before operation routing when set
+	// handlers can read the authenticated principal with Principal(ctx)
+	Authenticator Authenticator `json:"-"`
+
+	// Authorizer rejects requests with 403 after the ContextBuilder when set,
+	// dry runs and idempotent replays included
+	Authorizer Authorizer `json:"-"`
+
+	// ValidateTags checks `validate:"..."` struct tags of requests with
+	// github.com/go-playground/validator before ms.Validator is called
+	ValidateTags bool
+
+	// ValidateResponses checks JSON results against the ResponseSchema() of
+	// operations implementing SchemaOper, a mismatch is logged and answered with 500
+	ValidateResponses bool
+
+	// StrictBody rejects a request body with 400 for operations without a ReqType,
+	// by default such a body is ignored
+	StrictBody bool
+
+	// CursorParam is the query parameter of the page cursors in Link headers
+	// of Paginated results, "cursor" by default
+	CursorParam string
+
+	// DisallowUnknownFields rejects JSON bodies with fields not in the request type
+	// UseNumber decodes JSON numbers into interface{} fields as json.Number instead of float64
+	DisallowUnknownFields bool
+	UseNumber             bool
+
+	// Decoders are added to the default request body decoders, keyed by media type
+	// the defaults are "application/json" and "application/x-www-form-urlencoded"
+	Decoders map[string]Decoder `json:"-"`
+
+	// PrettyJSON indents responses for debugging, compact output is the default
+	PrettyJSON bool
+
+	// JSONKeyCase renames the struct fields of JSON results to "snake" or "camel" case,
+	// including names set by json tags but not map keys, "" keeps the encoding/json names
+	JSONKeyCase string
+
+	// ETag sets a SHA-256 ETag on encoded responses and answers GET and HEAD
+	// requests with a matching If-None-Match with 304 Not Modified,
+	// a gzip response has a different ETag than the uncompressed response
+	ETag bool
+
+	// Encoders are added to the default response encoders, keyed by media type
+	// the defaults are "application/json" and "application/xml", selected by the Accept header
+	Encoders map[string]Encoder `json:"-"`
+
+	// TracerProvider creates an OpenTelemetry span per request when set,
+	// continuing traces from incoming headers read by Propagator (default W3C trace context)
+	// the span is in the operation context for handlers to create child spans
+	TracerProvider trace.TracerProvider          `json:"-"`
+	Propagator     propagation.TextMapPropagator `json:"-"`
+
+	// LogLevel is debug, info (default) or error
+	LogLevel string
+
+	// AccessLog writes a JSON line per request to AccessLogWriter (default os.Stdout)
+	AccessLog       bool
+	AccessLogWriter io.Writer `json:"-"`
+
+	// LogBodies adds JSON request and response bodies to the access log
+	// and to error logs of failed requests, with RedactFields masked
+	LogBodies bool
+
+	// RedactFields are JSON field names masked as "****" in logged bodies,
+	// defaults to password, secret, token, accessToken, refreshToken and apiKey
+	RedactFields []string
+
+	// Middleware wraps the server handler, see Use()
+	Middleware []Middleware `json:"-"`
+
+	// OpenAPIPath serves an OpenAPI document describing the operations, e.g. "/openapi.json"
+	OpenAPIPath    string
+	OpenAPITitle   string
+	OpenAPIVersion string
+
+	// BatchPath serves batches of operation invocations in one POST, e.g. "/batch"
+	// with up to MaxBatchSize items (default 20), see BatchItem
+	BatchPath    string
+	MaxBatchSize int
+
+	// Handlers serve all paths under a prefix such as "/ui/" instead of operations,
+	// e.g. an http.FileServer, the longest matching prefix is used
+	// prefixes that could match a PathOper path or a version are rejected
+	Handlers map[string]http.Handler `json:"-"`
+
+	// NotFoundHandler answers requests for unknown operations instead of the default 404 error
+	NotFoundHandler http.Handler `json:"-"`
+
+	// EnablePprof serves the net/http/pprof profiles under PprofPrefix ("/debug/pprof/")
+	// it exposes internals and should only be enabled on a private network
+	EnablePprof bool
+
+	// OperationsPath, e.g. "/_operations", lists the operations with a summary of their
+	// request types, it exposes the API surface so it requires AdminPort or Debug
+	OperationsPath string
+
+	// Debug adds details intended for development to responses,
+	// such as the list of operations when an unknown operation is requested
+	// and a Server-Timing header with the decode, validate and handle durations
+	Debug bool
+
+	// ContextBuilder is called after authentication and request decoding
+	// to enrich the context passed to the operation from the HTTP request
+	ContextBuilder ContextBuilder `json:"-"`
+
+	// BeforeHandle is called just before an operation handler, e.g. for auditing,
+	// and an error fails the request with the status resolved from the error
+	BeforeHandle func(ctx ms.Context, operName string, req interface{}) error `json:"-"`
+
+	// AfterHandle is called with the result and error as soon as an operation handler returns
+	AfterHandle func(ctx ms.Context, operName string, res interface{}, err error) `json:"-"`
+
+	// ErrorMapper maps errors without an HTTP code, such as sentinel errors,
+	// to a status code, returning 0 to fall back to the Code() of the error
+	ErrorMapper ErrorMapper `json:"-"`
+
+	// GRPCStatusTrailers adds grpc-status and grpc-message trailers derived from the
+	// response status and error for gRPC-Web style clients, responses are then chunked
+	GRPCStatusTrailers bool
+
+	// ExposeErrorDetails writes the message and details of errors with status >= 500
+	// to clients, else they only get the status text, e.g. "internal server error",
+	// and the error code, while the full error is still logged, e.g. for staging
+	ExposeErrorDetails bool
+
+	// ErrorWriter writes error responses, defaults to WriteJSONError
+	ErrorWriter ErrorWriter `json:"-"`
+
+	// ProblemDetails writes errors as RFC 7807 application/problem+json when ErrorWriter is not set
+	// ProblemType is the type URI of errors without a ProblemTyper, "about:blank" by default
+	ProblemDetails bool
+	ProblemType    string
+
+	// OnServerError is called after a 5xx error response was written, including recovered panics,
+	// e.g. to alert operations, operName is "" when the operation was not resolved
+	// it is called on the request goroutine and must not block, so start a goroutine for slow notifications
+	OnServerError func(httpReq *http.Request, operName string, err error, code int) `json:"-"`
+
+	// Translator localizes error messages for the locale negotiated from the
+	// Accept-Language header among Locales, DefaultLocale ("en" by default) is used when none match
+	// the locale is set as Content-Language on error responses
+	Translator    Translator `json:"-"`
+	Locales       []string
+	DefaultLocale string
 }
 
 func (c Config) Validate() error {
-	if c.Addr == "" {
-		return errors.Errorf("missing addr")
+	if c.UnixSocket != "" {
+		if c.Addr != "" || c.Port != 0 {
+			return errors.Errorf("unixSocket cannot be combined with addr and port")
+		}
+		if c.TCPKeepAlive != 0 {
+			return errors.Errorf("tcpKeepAlive cannot be combined with unixSocket")
+		}
+	} else {
+		if c.Addr == "" {
+			return errors.Errorf("missing addr")
+		}
+		if c.Port < 0 || c.Port > 65535 {
+			return errors.Errorf("invalid port:%d", c.Port)
+		}
+	}
+	if c.AdminPort != 0 {
+		if c.UnixSocket != "" {
+			return errors.Errorf("adminPort cannot be combined with unixSocket")
+		}
+		if c.AdminPort < 0 || c.AdminPort > 65535 {
+			return errors.Errorf("adminPort:%d must be 1..65535", c.AdminPort)
+		}
+		if c.AdminPort == c.Port {
+			return errors.Errorf("adminPort:%d must differ from port", c.AdminPort)
+		}
+	}
+	if c.ReadTimeout < 0 {
+		return errors.Errorf("negative readTimeout:%v", c.ReadTimeout)
+	}
+	if c.WriteTimeout < 0 {
+		return errors.Errorf("negative writeTimeout:%v", c.WriteTimeout)
+	}
+	if c.IdleTimeout < 0 {
+		return errors.Errorf("negative idleTimeout:%v", c.IdleTimeout)
+	}
+	if c.DisableKeepAlives && c.IdleTimeout > 0 {
+		return errors.Errorf("idleTimeout cannot be combined with disableKeepAlives")
+	}
+	if c.HandlerTimeout < 0 {
+		return errors.Errorf("negative handlerTimeout:%v", c.HandlerTimeout)
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		return err
+	}
+	if c.MaxHeaderBytes < 0 {
+		return errors.Errorf("negative maxHeaderBytes:%d", c.MaxHeaderBytes)
+	}
+	if c.ShutdownTimeout < 0 {
+		return errors.Errorf("negative shutdownTimeout:%v", c.ShutdownTimeout)
+	}
+	if c.SSEKeepAlive < 0 {
+		return errors.Errorf("negative sseKeepAlive:%v", c.SSEKeepAlive)
+	}
+	if c.PollTimeout < 0 {
+		return errors.Errorf("negative pollTimeout:%v", c.PollTimeout)
+	}
+	if c.MaxClientTimeout < 0 {
+		return errors.Errorf("negative maxClientTimeout:%v", c.MaxClientTimeout)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.Errorf("certFile and keyFile must both be set or both be empty")
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		return errors.Errorf("clientCAFile requires certFile and keyFile")
+	}
+	if c.H2C && c.CertFile != "" {
+		return errors.Errorf("h2c cannot be combined with TLS")
+	}
+	if c.GzipMinBytes < 0 {
+		return errors.Errorf("negative gzipMinBytes:%d", c.GzipMinBytes)
+	}
+	for name, path := range map[string]string{"healthPath": c.HealthPath, "readyPath": c.ReadyPath, "metricsPath": c.MetricsPath, "openAPIPath": c.OpenAPIPath, "batchPath": c.BatchPath, "operationsPath": c.OperationsPath} {
+		if path != "" && !strings.HasPrefix(path, "/") {
+			return errors.Errorf("%s %q does not start with /", name, path)
+		}
+	}
+	if c.OperationsPath != "" && c.AdminPort == 0 && !c.Debug {
+		return errors.Errorf("operationsPath requires adminPort or debug")
+	}
+	switch c.TrailingSlash {
+	case TrailingSlashStrict, TrailingSlashStrip, TrailingSlashRedirect, TrailingSlashReject:
+	default:
+		return errors.Errorf("invalid trailingSlash:%q, expecting strip|redirect|reject", c.TrailingSlash)
+	}
+	if err := checkAliases(c.Aliases); err != nil {
+		return errors.Wrapf(err, "invalid aliases")
+	}
+	if c.JSONKeyCase != "" {
+		if _, err := keyCaser(c.JSONKeyCase); err != nil {
+			return err
+		}
+	}
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		return errors.Wrapf(err, "invalid trustedProxies")
+	}
+	for prefix := range c.Services {
+		if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+			return errors.Errorf("service prefix %q must start with / and cannot end with /", prefix)
+		}
+		for version := range c.Versions {
+			if prefix == "/"+version || strings.HasPrefix(prefix, "/"+version+"/") {
+				return errors.Errorf("service prefix %q conflicts with version %s", prefix, version)
+			}
+		}
+		for handlerPrefix := range c.Handlers {
+			if strings.HasPrefix(prefix+"/", handlerPrefix) || strings.HasPrefix(handlerPrefix, prefix+"/") {
+				return errors.Errorf("service prefix %q conflicts with handler prefix %q", prefix, handlerPrefix)
+			}
+		}
+	}
+	for prefix := range c.Handlers {
+		if !strings.HasPrefix(prefix, "/") || !strings.HasSuffix(prefix, "/") || prefix == "/" {
+			return errors.Errorf("handler prefix %q must start and end with / and cannot be /", prefix)
+		}
 	}
-	if c.Port == 0 {
-		return errors.Errorf("missing port")
+	if c.MaxPathLength < 0 {
+		return errors.Errorf("negative maxPathLength:%d", c.MaxPathLength)
 	}
-	if c.Port < 0 {
-		return errors.Errorf("negative port:%d", c.Port)
+	if c.MaxBatchSize < 0 {
+		return errors.Errorf("negative maxBatchSize:%d", c.MaxBatchSize)
+	}
+	if c.MaxConcurrentRequests < 0 {
+		return errors.Errorf("negative maxConcurrentRequests:%d", c.MaxConcurrentRequests)
+	}
+	if c.Idempotency != nil {
+		if err := c.Idempotency.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid idempotency")
+		}
+	}
+	if c.RateLimit != nil {
+		if err := c.RateLimit.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid rateLimit")
+		}
+	}
+	if c.CircuitBreaker != nil {
+		if err := c.CircuitBreaker.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid circuitBreaker")
+		}
+	}
+	if c.CORS != nil {
+		if err := c.CORS.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid cors")
+		}
 	}
 	return nil
 }
 
 func (c Config) Create(ms ms.MicroService) (ms.Server, error) {
-	return server{
-		ms:   ms,
-		addr: fmt.Sprintf("%s:%d", c.Addr, c.Port),
-	}, nil
+	return c.newServer(ms)
+}
+
+// Handler returns the HTTP handler of a server with this config, including
+// middleware, without listening, e.g. to test with net/http/httptest
+// Addr and Port are not used
+func (c Config) Handler(ms ms.MicroService) (http.Handler, error) {
+	s, err := c.newServer(ms)
+	if err != nil {
+		return nil, err
+	}
+	s.listening.Store(true) //the caller serves the handler
+	return s.httpServer.Handler, nil
+}
+
+// Handler returns the HTTP handler of a server with the default config
+func Handler(ms ms.MicroService) (http.Handler, error) {
+	return Config{}.Handler(ms)
+}
+
+func (c Config) newServer(ms ms.MicroService) (*server, error) {
+	svc, err := newService(ms, c.CaseInsensitivePaths)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]*service{}
+	for version, versionMS := range c.Versions {
+		if versions[version], err = newService(versionMS, c.CaseInsensitivePaths); err != nil {
+			return nil, errors.Wrapf(err, "invalid version %s", version)
+		}
+	}
+	services, err := newServices(c.Services, c.CaseInsensitivePaths)
+	if err != nil {
+		return nil, err
+	}
+	if c.TracerProvider != nil && c.Propagator == nil {
+		c.Propagator = propagation.TraceContext{}
+	}
+	if c.GzipMinBytes == 0 {
+		c.GzipMinBytes = defaultGzipMinBytes
+	}
+	if c.HealthPath == "" {
+		c.HealthPath = defaultHealthPath
+	}
+	if c.ReadyPath == "" {
+		c.ReadyPath = defaultReadyPath
+	}
+	if c.MetricsPath != "" && c.Metrics == nil {
+		c.Metrics = NewPrometheusMetrics()
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if c.AccessLog && c.AccessLogWriter == nil {
+		c.AccessLogWriter = os.Stdout
+	}
+	if c.OpenAPITitle == "" {
+		c.OpenAPITitle = "API"
+	}
+	if c.OpenAPIVersion == "" {
+		c.OpenAPIVersion = "1.0.0"
+	}
+	if c.Idempotency != nil {
+		idempotency := *c.Idempotency
+		if idempotency.TTL == 0 {
+			idempotency.TTL = defaultIdempotencyTTL
+		}
+		if idempotency.Store == nil {
+			idempotency.Store = NewMemoryIdempotencyStore()
+		}
+		c.Idempotency = &idempotency
+	}
+	if c.ErrorWriter == nil && c.ProblemDetails {
+		c.ErrorWriter = NewProblemWriter(c.ProblemType)
+	}
+	if c.ErrorWriter == nil {
+		c.ErrorWriter = WriteJSONError
+	}
+	if c.SSEKeepAlive == 0 {
+		c.SSEKeepAlive = defaultSSEKeepAlive
+	}
+	if c.PollTimeout == 0 {
+		c.PollTimeout = defaultPollTimeout
+	}
+	if c.CursorParam == "" {
+		c.CursorParam = defaultCursorParam
+	}
+	if c.MaxBatchSize == 0 {
+		c.MaxBatchSize = defaultMaxBatchSize
+	}
+	if c.DefaultLocale == "" {
+		c.DefaultLocale = defaultLocale
+	}
+	trustedProxies, err := parseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid trustedProxies")
+	}
+	addr := fmt.Sprintf("%s:%d", c.Addr, c.Port)
+	if c.UnixSocket != "" {
+		addr = c.UnixSocket
+	}
+	s := &server{
+		config:   c,
+		ms:       ms,
+		addr:     addr,
+		svc:      svc,
+		versions: versions,
+		services: services,
+		proxies:  trustedProxies,
+	}
+	logLevel, err := parseLogLevel(c.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	s.log = logger.New().WithLevel(logLevel)
+	s.decoders = defaultDecoders(c)
+	for mediaType, decoder := range c.Decoders {
+		s.decoders[mediaType] = decoder
+	}
+	s.encoders = defaultEncoders(c)
+	if c.JSONKeyCase != "" {
+		rename, err := keyCaser(c.JSONKeyCase)
+		if err != nil {
+			return nil, err
+		}
+		s.encoders["application/json"] = newKeyCaseEncoder(rename, c.PrettyJSON)
+	}
+	for mediaType, encoder := range c.Encoders {
+		s.encoders[mediaType] = encoder
+	}
+	if c.ValidateTags {
+		s.tagValidator = newTagValidator()
+	}
+	if c.TracerProvider != nil {
+		s.tracer = c.TracerProvider.Tracer(tracerName)
+	}
+	if c.MaxConcurrentRequests > 0 {
+		s.semaphore = make(chan struct{}, c.MaxConcurrentRequests)
+	}
+	if c.RateLimit != nil {
+		s.rateLimiter = newRateLimiter(*c.RateLimit)
+	}
+	if c.CircuitBreaker != nil {
+		s.breakers = newBreakers(*c.CircuitBreaker, c.Metrics)
+	}
+	if c.LogBodies {
+		if c.RedactFields == nil {
+			c.RedactFields = defaultRedactFields
+		}
+		s.redactor = newRedactor(c.RedactFields)
+	}
+	if c.AccessLog {
+		s.accessLog = &accessLogger{w: c.AccessLogWriter, errLog: s.log}
+	}
+	management := map[string]http.Handler{
+		c.HealthPath: http.HandlerFunc(s.serveHealth),
+		c.ReadyPath:  http.HandlerFunc(s.serveReady),
+	}
+	if handler, ok := c.Metrics.(http.Handler); ok && c.MetricsPath != "" {
+		management[c.MetricsPath] = handler
+	}
+	if c.OperationsPath != "" {
+		management[c.OperationsPath] = http.HandlerFunc(s.serveOperations)
+	}
+	var pprofHandler http.Handler
+	if c.EnablePprof {
+		pprofHandler = newPprofHandler()
+	}
+	s.builtins = map[string]http.Handler{}
+	if c.OpenAPIPath != "" {
+		s.builtins[c.OpenAPIPath] = http.HandlerFunc(s.serveOpenAPI)
+	}
+	if c.BatchPath != "" {
+		s.builtins[c.BatchPath] = http.HandlerFunc(s.serveBatch)
+	}
+	if s.mounts, err = newMounts(c.Handlers, s.svc, s.versions); err != nil {
+		return nil, err
+	}
+	if c.AdminPort != 0 {
+		s.adminServer = &http.Server{
+			Addr:           net.JoinHostPort(c.Addr, strconv.Itoa(c.AdminPort)),
+			Handler:        adminHandler{s: s, endpoints: management, pprof: pprofHandler},
+			ReadTimeout:    c.ReadTimeout,
+			WriteTimeout:   c.WriteTimeout,
+			IdleTimeout:    c.IdleTimeout,
+			MaxHeaderBytes: c.MaxHeaderBytes,
+		}
+	} else {
+		for path, handler := range management {
+			s.builtins[path] = handler
+		}
+		if pprofHandler != nil {
+			if err := checkPrefixRoutes(s.svc.routes, PprofPrefix); err != nil {
+				return nil, errors.Wrapf(err, "cannot enable pprof")
+			}
+			s.pprof = pprofHandler
+		}
+	}
+	handler := chain(s, c.Middleware)
+	if c.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: c.IdleTimeout})
+	}
+	s.httpServer = &http.Server{
+		Addr:           s.addr,
+		Handler:        handler,
+		ReadTimeout:    c.ReadTimeout,
+		WriteTimeout:   c.WriteTimeout,
+		IdleTimeout:    c.IdleTimeout,
+		MaxHeaderBytes: c.MaxHeaderBytes,
+	}
+	if c.DisableKeepAlives {
+		s.httpServer.SetKeepAlivesEnabled(false)
+	}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read clientCAFile %s", c.ClientCAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in clientCAFile %s", c.ClientCAFile)
+		}
+		s.httpServer.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+		}
+	}
+	return s, nil
 }
 
 type server struct {
-	ms   ms.MicroService
-	addr string
+	config       Config
+	log          leveledLogger
+	ms           ms.MicroService
+	mutex        sync.Mutex
+	addr         string //actual address once listening
+	adminAddr    string //actual admin address once listening
+	svc          *service
+	versions     map[string]*service
+	services     []*service //Config.Services by longest prefix first
+	proxies      []*net.IPNet
+	builtins     map[string]http.Handler //by path, served before operation routing
+	pprof        http.Handler            //serves PprofPrefix when enabled
+	mounts       []mount                 //Config.Handlers by longest prefix first
+	decoders     map[string]Decoder      //by media type
+	encoders     map[string]Encoder      //by media type
+	accessLog    *accessLogger
+	rateLimiter  *rateLimiter
+	breakers     *breakers     //set with Config.CircuitBreaker
+	semaphore    chan struct{} //limits concurrent requests when not nil
+	tagValidator *validator.Validate
+	redactor     redactor     //set when bodies are logged
+	tracer       trace.Tracer //set when tracing
+	inflight     singleflight.Group
+	httpServer   *http.Server
+	adminServer  *http.Server //nil without AdminPort
+	listening    atomic.Bool  //set once Serve is listening
+	started      atomic.Bool  //set once listening and the micro-service was ready
+	draining     atomic.Bool  //set by Shutdown
+}
+
+// Serve blocks until the server stops
+// it returns nil when stopped with Shutdown()
+func (s *server) Serve() error {
+	adminErrs := make(chan error, 1)
+	if s.adminServer != nil {
+		adminListener, err := net.Listen("tcp", s.adminServer.Addr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to listen on admin %s", s.adminServer.Addr)
+		}
+		s.mutex.Lock()
+		s.adminAddr = adminListener.Addr().String()
+		s.mutex.Unlock()
+		s.log.Infof("HTTP admin server listen on %s", adminListener.Addr())
+		go func() {
+			if err := s.adminServer.Serve(adminListener); err != nil && err != http.ErrServerClosed {
+				adminErrs <- errors.Wrapf(err, "HTTP admin server on %s failed", adminListener.Addr())
+				s.httpServer.Close() //stop serving operations without health checks
+			}
+		}()
+	}
+	listener, err := s.listen()
+	if err != nil {
+		if s.adminServer != nil {
+			s.adminServer.Close()
+		}
+		return errors.Wrapf(err, "failed to listen on %s", s.addr)
+	}
+	s.mutex.Lock()
+	s.addr = listener.Addr().String()
+	s.mutex.Unlock()
+	s.listening.Store(true)
+	if s.config.OnListen != nil {
+		s.config.OnListen(listener.Addr())
+	}
+	if s.config.CertFile != "" {
+		s.log.Infof("HTTPS REST server listen on %s", listener.Addr())
+		err = s.httpServer.ServeTLS(listener, s.config.CertFile, s.config.KeyFile)
+	} else {
+		s.log.Infof("HTTP REST server listen on %s", listener.Addr())
+		err = s.httpServer.Serve(listener)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		return errors.Wrapf(err, "HTTP server on %s failed", listener.Addr())
+	}
+	select {
+	case adminErr := <-adminErrs:
+		return adminErr
+	default:
+		return nil
+	}
 }
 
-func (s server) Serve() error {
-	log.Infof("HTTP REST server listen on %s", s.addr)
-	return http.ListenAndServe(s.addr, s)
+// Addr returns the address the server listens on
+// which differs from the config when port 0 was used
+func (s *server) Addr() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addr
 }
 
-func (s server) ServeHTTP(httpRes http.ResponseWriter, httpReq *http.Request) {
-	log.Infof("HTTP %s %s", httpReq.Method, httpReq.URL.Path)
+// Shutdown stops accepting new connections and waits for in-flight requests
+// to complete, for Config.ShutdownTimeout or for ctx to expire, whichever comes first.
+// While draining, readiness reports not ready and new requests get 503.
+// Connections still active when the wait ends are closed.
+func (s *server) Shutdown(ctx context.Context) error {
+	s.log.Infof("HTTP REST server on %s shutting down", s.Addr())
+	s.draining.Store(true)
+	if s.config.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.ShutdownTimeout)
+		defer cancel()
+	}
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.log.Errorf("HTTP REST server on %s did not drain: %+v, closing remaining connections", s.Addr(), err)
+		s.httpServer.Close()
+	}
+	//the admin server keeps reporting not ready until operations are drained
+	if s.adminServer != nil {
+		if adminErr := s.adminServer.Shutdown(ctx); adminErr != nil {
+			s.adminServer.Close()
+			if err == nil {
+				err = adminErr
+			}
+		}
+	}
+	return err
+}
+
+// builtin returns the handler of a built-in endpoint on path, or nil for operations
+func (s *server) builtin(path string) http.Handler {
+	if handler, ok := s.builtins[path]; ok {
+		return handler
+	}
+	if s.pprof != nil && isPprofPath(path) {
+		return s.pprof
+	}
+	return nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, httpReq *http.Request) {
+	start := time.Now()
+	httpRes := newResponseWriter(w)
+	requestID, causationID := requestIDs(httpReq.Header, s.config.CausationIDs)
+	httpRes.Header().Set(RequestIDHeader, requestID)
+	if causationID != "" {
+		httpRes.Header().Set(CausationIDHeader, causationID)
+	}
+	if s.config.GRPCStatusTrailers {
+		declareGRPCTrailers(httpRes)
+	}
+	rlog := requestLogger{id: requestID, log: s.log}
+	ip := clientIP(httpReq, s.proxies)
+	rlog.Debugf("HTTP %s %s", httpReq.Method, httpReq.URL.Path)
+	var span trace.Span
+	if s.tracer != nil {
+		httpReq, span = s.startSpan(httpReq)
+	}
 
 	var err error
+	var observedOperName string //only set once the operation is known, to limit metric labels
+	var erroredOper ms.Oper     //set once the operation is known, to map its errors
+	var reqBody *bodyCapture    //set when bodies are logged
+	var resBody []byte
+	var dryRun bool //set once a dry run request is validated
 	defer func() {
+		if r := recover(); r != nil {
+			rlog.Errorf("HTTP %s %s panic: %v\n%s", httpReq.Method, httpReq.URL.Path, r, debug.Stack())
+			err = errors.Errorc(http.StatusInternalServerError, fmt.Sprintf("panic: %v", r))
+		}
 		if err != nil {
-			errCode := http.StatusInternalServerError
-			if e, ok := err.(errors.IError); ok {
-				if http.StatusText(e.Code()) != "" {
-					errCode = e.Code()
-				}
-				log.Infof("code:%v->%v from err:%+v", errCode, http.StatusText(errCode), err)
+			errCode := errorCode(err, operErrorMapper(erroredOper), s.config.ErrorMapper)
+			if errCode == http.StatusUpgradeRequired {
+				httpRes.Header().Set("Upgrade", "websocket")
 			}
+			rlog.Debugf("code:%v->%v from err:%+v", errCode, http.StatusText(errCode), err)
 			if errCode >= 500 {
-				log.Errorf("HTTP %s %s -> %d %s: %+v", httpReq.Method, httpReq.URL.Path, errCode, http.StatusText(errCode), err)
+				if reqBody != nil {
+					rlog.Errorf("HTTP %s %s -> %d %s: %+v (request body: %s)", httpReq.Method, httpReq.URL.Path, errCode, http.StatusText(errCode), err, s.redactor.body(reqBody.Bytes(), reqBody.truncated))
+				} else {
+					rlog.Errorf("HTTP %s %s -> %d %s: %+v", httpReq.Method, httpReq.URL.Path, errCode, http.StatusText(errCode), err)
+				}
 			}
-			http.Error(httpRes, err.Error(), errCode)
-			return
+			clientErr := s.exposedError(err, errCode)
+			if s.config.Translator != nil {
+				locale := s.requestLocale(httpReq)
+				httpRes.Header().Set("Content-Language", locale)
+				s.config.ErrorWriter(httpRes, httpReq, errCode, localize(s.config.Translator, locale, errCode, clientErr))
+			} else {
+				s.config.ErrorWriter(httpRes, httpReq, errCode, clientErr)
+			}
+			if errCode >= 500 && s.config.OnServerError != nil {
+				s.config.OnServerError(httpReq, observedOperName, err, errCode)
+			}
+		}
+		if s.config.GRPCStatusTrailers {
+			setGRPCTrailers(httpRes, httpRes.Status(), s.exposedError(err, httpRes.Status()))
+		}
+		if span != nil {
+			if observedOperName != "" {
+				span.SetName(observedOperName)
+			}
+			endSpan(span, httpRes.Status(), err)
+		}
+		if s.config.Metrics != nil && observedOperName != "" {
+			s.config.Metrics.Observe(observedOperName, httpRes.Status(), time.Since(start))
+		}
+		if s.accessLog != nil {
+			entry := accessLogEntry{
+				Time:        start.UTC().Format(time.RFC3339Nano),
+				Method:      httpReq.Method,
+				Path:        httpReq.URL.Path,
+				Operation:   observedOperName,
+				Status:      httpRes.Status(),
+				DurationMs:  durationMs(time.Since(start)),
+				Bytes:       httpRes.BytesWritten(),
+				RequestID:   requestID,
+				CausationID: causationID,
+				DryRun:      dryRun,
+			}
+			if reqBody != nil {
+				entry.RequestBody = s.redactor.body(reqBody.Bytes(), reqBody.truncated)
+			}
+			if s.redactor != nil && err == nil {
+				entry.ResponseBody = s.redactor.body(resBody, false)
+			}
+			s.accessLog.log(entry)
 		}
-		//success
 	}()
 
-	//get operation name from first part of URL path e.g. GET "/<oper>""
+	if s.config.MaxPathLength > 0 && len(httpReq.URL.EscapedPath()) > s.config.MaxPathLength {
+		err = errors.Errorc(http.StatusRequestURITooLong, fmt.Sprintf("URL path exceeds %d bytes", s.config.MaxPathLength))
+		return
+	}
+
+	if handler := s.builtin(httpReq.URL.Path); handler != nil {
+		handler.ServeHTTP(httpRes, httpReq)
+		return
+	}
+
+	if s.draining.Load() {
+		httpRes.Header().Set("Connection", "close")
+		err = errors.Errorc(http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+
+	if handler := s.mounted(httpReq.URL.Path); handler != nil {
+		handler.ServeHTTP(httpRes, httpReq)
+		return
+	}
+
+	if !s.startupComplete() {
+		httpRes.Header().Set("Retry-After", "1")
+		err = errors.Errorc(http.StatusServiceUnavailable, "server is starting")
+		return
+	}
+
+	if s.semaphore != nil {
+		select {
+		case s.semaphore <- struct{}{}:
+			defer func() { <-s.semaphore }() //also released on panic
+		default:
+			httpRes.Header().Set("Retry-After", "1")
+			err = errors.Errorc(http.StatusServiceUnavailable, "too many concurrent requests")
+			return
+		}
+	}
+
+	if s.config.CORS != nil {
+		var preflight bool
+		if preflight, err = s.config.CORS.handle(httpRes, httpReq); preflight || err != nil {
+			return
+		}
+	}
+
+	var principal interface{}
+	if s.config.Authenticator != nil {
+		if principal, err = s.config.Authenticator(httpReq); err != nil {
+			challenge := defaultAuthChallenge
+			if authErr, ok := err.(AuthError); ok && authErr.Challenge != "" {
+				challenge = authErr.Challenge
+			}
+			httpRes.Header().Set("WWW-Authenticate", challenge)
+			err = errors.Errorc(http.StatusUnauthorized, fmt.Sprintf("unauthorized: %v", err))
+			return
+		}
+	}
+
+	svc, reqURL, err := s.serviceFor(httpReq)
+	if err != nil {
+		return
+	}
+
+	if hasTrailingSlash(reqURL) {
+		switch s.config.TrailingSlash {
+		case TrailingSlashStrip:
+			reqURL = withoutTrailingSlash(reqURL)
+		case TrailingSlashRedirect:
+			code := http.StatusMovedPermanently
+			if httpReq.Method != http.MethodGet && httpReq.Method != http.MethodHead {
+				code = http.StatusPermanentRedirect //keep the method and body
+			}
+			http.Redirect(httpRes, httpReq, withoutTrailingSlash(httpReq.URL).String(), code)
+			return
+		case TrailingSlashReject:
+			err = errors.Errorc(http.StatusBadRequest, "URL path has a trailing slash")
+			return
+		}
+	}
+
+	//get operation name from a templated route, else from first part of URL path e.g. GET "/<oper>""
 	var operName string
-	{
-		names := strings.SplitN(httpReq.URL.Path, "/", 2)
+	var pathParams map[string]string
+	if operName, pathParams, err = matchRoute(svc.routes, reqURL); err != nil {
+		err = errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid URL path: %+v", err))
+		return
+	}
+	if operName == "" && s.config.OperationNameResolver != nil {
+		resolveReq := new(http.Request)
+		*resolveReq = *httpReq
+		resolveReq.URL = reqURL
+		if operName, err = s.config.OperationNameResolver(resolveReq); err != nil {
+			if _, ok := err.(codeError); !ok {
+				err = errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid URL path: %+v", err))
+			}
+			return
+		}
+	} else if operName == "" {
+		names := strings.SplitN(reqURL.Path, "/", 2)
 		if len(names) < 2 || len(names[0]) != 0 || len(names[1]) == 0 {
 			err = errors.Errorc(http.StatusBadRequest, "URL does not start with /<operName>")
 			return
 		}
 		operName = names[1]
 	}
-	oper, ok := s.ms.Oper(operName)
+	operName, oper, ok := svc.oper(operName)
+	if target, _ := resolveAlias(s.config.Aliases, operName); !ok && target != operName {
+		if targetName, targetOper, found := svc.oper(target); found {
+			rlog.Infof("deprecated alias %s used for %s", operName, targetName)
+			httpRes.Header().Set(DeprecationHeader, "true")
+			operName, oper, ok = targetName, targetOper, true
+		}
+	}
 	if !ok {
-		err = errors.Errorc(http.StatusNotFound, fmt.Sprintf("unknown operation %s != %s", operName, strings.Join(s.ms.OperNames(), "|")))
+		if s.config.NotFoundHandler != nil {
+			s.config.NotFoundHandler.ServeHTTP(httpRes, httpReq)
+			return
+		}
+		if s.config.Debug {
+			err = withErrorCode(ErrorCodeUnknownOperation, errors.Errorc(http.StatusNotFound, fmt.Sprintf("unknown operation %s != %s", operName, strings.Join(svc.ms.OperNames(), "|"))))
+			return
+		}
+		err = withErrorCode(ErrorCodeUnknownOperation, errors.Errorc(http.StatusNotFound, "unknown operation"))
 		return
 	}
+	observedOperName = operName
+	erroredOper = oper
+	if deprecated(httpRes.Header(), oper) {
+		rlog.Infof("deprecated operation %s called", operName)
+	}
+	if methodOper, ok := oper.(MethodOper); ok {
+		if methods := methodOper.Methods(); len(methods) > 0 && !methodAllowed(httpReq.Method, methods) {
+			httpRes.Header().Set("Allow", strings.Join(methods, ", "))
+			err = withErrorCode(ErrorCodeMethodNotAllowed, errors.Errorc(http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed for %s", httpReq.Method, operName)))
+			return
+		}
+	}
+
+	if s.rateLimiter != nil {
+		if ok, retryAfter := s.rateLimiter.allow(httpReq, ip, operName, time.Now()); !ok {
+			httpRes.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			err = errors.Errorc(http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+	}
+
+	//select the response encoder before handling so that the request is not processed if it cannot be answered
+	_, isEventStream := oper.(EventStreamOper)
+	var resContentType string
+	var encoder Encoder
+	if !isEventStream {
+		if resContentType, encoder, err = s.encoderFor(httpReq); err != nil {
+			return
+		}
+	}
+
+	if encoding := httpReq.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		if httpReq.Body, err = decompressBody(httpReq.Body, encoding); err != nil {
+			return
+		}
+		httpReq.Header.Del("Content-Encoding")
+		httpReq.ContentLength = -1
+	}
+	if s.config.MaxBodyBytes > 0 {
+		httpReq.Body = http.MaxBytesReader(httpRes, httpReq.Body, s.config.MaxBodyBytes)
+	}
+	rawBodyOper, isRawBody := oper.(RawBodyOper)
+	isRawBody = isRawBody && rawBodyOper.RawBody()
+	var dedupKeyValue string
+	if s.config.DedupRequests && dedupMethod(httpReq.Method) && !isRawBody {
+		var body []byte
+		if body, err = s.readBody(httpReq); err != nil {
+			return
+		}
+		dedupKeyValue = dedupKey(operName, principal, oper.ReqType(), httpReq, body)
+	}
+	var idempotencyKey, fingerprint string
+	if s.config.Idempotency != nil {
+		idempotencyKey = httpReq.Header.Get(IdempotencyKeyHeader)
+	}
+	if idempotencyKey != "" {
+		var body []byte
+		if !isRawBody { //a raw body is streamed to the handler, so only its method and URL are compared
+			if body, err = s.readBody(httpReq); err != nil {
+				return
+			}
+		}
+		fingerprint = requestFingerprint(httpReq, body)
+	}
+	var timing *serverTiming
+	if s.config.Debug {
+		timing = newServerTiming()
+	}
+	schemas := svc.schemas[operName]
+	if schemas.req != nil && isJSONRequest(httpReq) && !isRawBody {
+		validateStart := time.Now()
+		var body []byte
+		if body, err = s.readBody(httpReq); err != nil {
+			return
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			err = validateSchema(schemas.req, body)
+			timing.observe(httpRes.Header(), "validate", validateStart)
+			if err != nil {
+				if _, ok := err.(ValidationError); !ok {
+					err = withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("failed to decode body: %+v", err)))
+				}
+				return
+			}
+		}
+	}
+	if s.redactor != nil {
+		reqBody = &bodyCapture{}
+		httpReq.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(httpReq.Body, reqBody), httpReq.Body}
+	}
 
 	var req interface{}
-	if oper.ReqType() != nil {
+	if isRawBody {
+		req = io.Reader(httpReq.Body)
+	} else if oper.ReqType() != nil {
+		decodeStart := time.Now()
 		reqPtrValue := reflect.New(oper.ReqType())
-		if err = json.NewDecoder(httpReq.Body).Decode(reqPtrValue.Interface()); err != nil && err != io.EOF {
-			err = errors.Errorc(http.StatusBadRequest, fmt.Sprintf("failed to decode body into %v: %+v", oper.ReqType(), err))
+		if oper.ReqType().Kind() == reflect.Map {
+			reqPtrValue.Elem().Set(reflect.MakeMap(oper.ReqType())) //so that handlers can add keys without a body
+		}
+		//query params are bound first so that body values take precedence
+		query := httpReq.URL.Query()
+		err = bindValues(reqPtrValue.Elem(), "query", func(name string) []string { return query[name] })
+		if queryBody, ok := oper.(QueryBodyOper); ok && err == nil && queryBody.QueryBody() {
+			err = bindJSONNames(reqPtrValue.Elem(), func(name string) []string { return query[name] })
+		}
+		if err != nil {
+			err = withErrorCode(ErrorCodeInvalidParameter, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("failed to decode query into %v: %+v", oper.ReqType(), err)))
+			return
+		}
+		var decoder Decoder
+		if decoder, err = s.decoderFor(httpReq); err != nil {
+			return
+		}
+		if err = decoder(httpReq, reqPtrValue.Interface()); err == io.EOF {
+			if bodyRequired, ok := oper.(BodyRequiredOper); ok && bodyRequired.BodyRequired() {
+				err = withErrorCode(ErrorCodeBodyRequired, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("%s requires a request body", operName)))
+				return
+			}
+		} else if err != nil {
+			if isMaxBytesError(err) {
+				err = errors.Errorc(http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", s.config.MaxBodyBytes))
+				return
+			}
+			if bodyErr, ok := jsonBodyError(err); ok {
+				err = bodyErr
+				return
+			}
+			if _, ok := err.(codeError); ok {
+				return //the decoder chose the status, e.g. 415 for a request type it cannot decode
+			}
+			err = withErrorCode(ErrorCodeInvalidBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("failed to decode body into %v: %+v", oper.ReqType(), err)))
+			return
+		}
+		//path parameters are bound after the body so that a body field cannot replace them
+		if err = bindValues(reqPtrValue.Elem(), "path", func(name string) []string {
+			if v, ok := pathParams[name]; ok {
+				return []string{v}
+			}
+			return nil
+		}); err != nil {
+			err = withErrorCode(ErrorCodeInvalidParameter, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("failed to decode path into %v: %+v", oper.ReqType(), err)))
+			return
+		}
+		err = bindValues(reqPtrValue.Elem(), "header", httpReq.Header.Values)
+		timing.observe(httpRes.Header(), "decode", decodeStart)
+		if err != nil {
+			err = withErrorCode(ErrorCodeInvalidParameter, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("failed to decode headers into %v: %+v", oper.ReqType(), err)))
 			return
 		}
+		validateStart := time.Now()
+		if s.tagValidator != nil {
+			if err = validateTags(s.tagValidator, reqPtrValue.Interface()); err != nil {
+				return
+			}
+		}
 		if validator, ok := reqPtrValue.Interface().(ms.Validator); ok {
 			if err = validator.Validate(); err != nil {
-				err = errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid request: %+v", err))
+				err = withErrorCode(ErrorCodeValidationFailed, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("invalid request: %+v", err)))
 				return
 			}
 		}
+		timing.observe(httpRes.Header(), "validate", validateStart)
 		req = reqPtrValue.Elem().Interface()
+	} else if s.config.StrictBody {
+		var b [1]byte
+		if n, _ := io.ReadFull(httpReq.Body, b[:]); n > 0 {
+			err = withErrorCode(ErrorCodeUnexpectedBody, errors.Errorc(http.StatusBadRequest, fmt.Sprintf("%s does not accept a request body", operName)))
+			return
+		}
 	}
 
-	ctx := s.ms.NewContext()
+	wsOper, isWebSocket := oper.(WebSocketOper)
+	isWebSocket = isWebSocket && isWebSocketUpgrade(httpReq)
+
+	handlerCtx := httpReq.Context()
+	timeout := s.handlerTimeout(httpReq, oper, rlog)
+	if timeout > 0 && !isWebSocket && !isEventStream {
+		var cancel context.CancelFunc
+		handlerCtx, cancel = context.WithTimeout(handlerCtx, timeout)
+		defer cancel()
+	}
+	reqCtx := newRequestContext(svc.ms.NewContext(), handlerCtx)
+	reqCtx.with(requestIDKey, requestID)
+	if causationID != "" {
+		reqCtx.with(causationIDKey, causationID)
+	}
+	reqCtx.with(clientIPKey, ip)
+	if principal != nil {
+		reqCtx.with(principalKey, principal)
+	}
+	if httpReq.TLS != nil && len(httpReq.TLS.VerifiedChains) > 0 && len(httpReq.TLS.VerifiedChains[0]) > 0 {
+		reqCtx.with(clientCertSubjectKey, httpReq.TLS.VerifiedChains[0][0].Subject.String())
+	}
+	addPreconditions(reqCtx, httpReq)
+	staged := &stagedHeaders{header: http.Header{}}
+	reqCtx.with(responseHeadersKey, staged)
+	if s.config.Translator != nil {
+		reqCtx.with(localeKey, s.requestLocale(httpReq))
+	}
+	var ctx ms.Context = reqCtx
+	if s.config.ContextBuilder != nil {
+		if ctx, err = s.config.ContextBuilder(reqCtx, httpReq); err != nil {
+			return
+		}
+	}
+	if s.config.Authorizer != nil {
+		if authErr := s.config.Authorizer(ctx, operName, req); authErr != nil {
+			err = errors.Errorc(http.StatusForbidden, fmt.Sprintf("forbidden: %v", authErr))
+			return
+		}
+	}
+
+	if isDryRun(httpReq, s.config.DryRunParam) {
+		dryRun = true
+		rlog.Infof("dry run of %s: request is valid, handler not called", operName)
+		if encoder == nil { //no encoder is negotiated for an event stream
+			resContentType, encoder = "application/json", s.encoders["application/json"]
+		}
+		if resBody, err = encoder(DryRunResult{DryRun: true, Operation: operName, Valid: true}); err != nil {
+			err = errors.Wrapf(err, "failed to encode %s dry run result as %s", operName, resContentType)
+			return
+		}
+		httpRes.Header().Set("Content-Type", resContentType)
+		httpRes.Header().Set("Content-Length", strconv.Itoa(len(resBody)))
+		httpRes.WriteHeader(http.StatusOK)
+		if httpReq.Method != http.MethodHead {
+			if _, writeErr := httpRes.Write(resBody); writeErr != nil {
+				rlog.Errorf("failed to write %s dry run result: %+v", operName, writeErr)
+			}
+		}
+		return
+	}
+
+	if idempotencyKey != "" {
+		store, ttl := s.config.Idempotency.Store, s.config.Idempotency.TTL
+		storeKey := idempotencyStoreKey(svc.prefix, operName, principal, httpReq, idempotencyKey)
+		var stored *StoredResponse
+		if stored, err = store.Reserve(storeKey, ttl); err != nil {
+			if err == ErrIdempotencyInFlight {
+				err = errors.Errorc(http.StatusConflict, err.Error())
+			}
+			return
+		}
+		if stored != nil {
+			if stored.Fingerprint != fingerprint {
+				err = errors.Errorc(http.StatusUnprocessableEntity, fmt.Sprintf("idempotency key %s was used for a different request", idempotencyKey))
+				return
+			}
+			rlog.Infof("replay %s response for idempotency key %s", operName, idempotencyKey)
+			replay(httpRes, stored)
+			return
+		}
+		httpRes.capture = &bytes.Buffer{}
+		defer func() {
+			//only keep complete successful responses, else allow a retry
+			if status := httpRes.Status(); err == nil && status >= 200 && status < 300 && !httpRes.streamed {
+				header := httpRes.Header().Clone()
+				header.Del(RequestIDHeader)
+				header.Del(CausationIDHeader)
+				if storeErr := store.Complete(storeKey, StoredResponse{Status: status, Header: header, Body: httpRes.capture.Bytes(), Fingerprint: fingerprint}, ttl); storeErr != nil {
+					rlog.Errorf("failed to store idempotent response: %+v", storeErr)
+				}
+				return
+			}
+			if releaseErr := store.Release(storeKey); releaseErr != nil {
+				rlog.Errorf("failed to release idempotency key: %+v", releaseErr)
+			}
+		}()
+	}
+
+	if isWebSocket {
+		s.serveWebSocket(httpRes, httpReq, ctx, req, wsOper, operName, rlog)
+		return
+	}
+	if isEventStream {
+		s.serveEvents(httpRes, httpReq, ctx, req, oper, operName, rlog)
+		return
+	}
+	if s.config.BeforeHandle != nil {
+		if err = s.config.BeforeHandle(ctx, operName, req); err != nil {
+			return
+		}
+	}
+	var breakerDone func(failed, counted bool) //set when the circuit breaker allowed the request
+	if s.breakers != nil && s.breakers.applies(operName) {
+		allowed, trial, retryAfter := s.breakers.allow(operName, time.Now())
+		if !allowed {
+			httpRes.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			err = errors.Errorc(http.StatusServiceUnavailable, fmt.Sprintf("%s circuit breaker is open", operName))
+			return
+		}
+		recorded := false
+		breakerDone = func(failed, counted bool) {
+			recorded = true
+			if !counted {
+				s.breakers.release(operName, trial)
+				return
+			}
+			s.breakers.record(operName, trial, failed, time.Now())
+		}
+		defer func() {
+			if !recorded {
+				breakerDone(true, true) //the handler panicked
+			}
+		}()
+	}
 	var res interface{}
-	res, err = oper.Handle(ctx, req)
+	handleStart := time.Now()
+	if dedupKeyValue != "" {
+		var shared, ran bool
+		var v interface{}
+		v, err, shared = s.inflight.Do(dedupKeyValue, func() (interface{}, error) {
+			ran = true
+			//the first request closed by its client must not cancel the handler for the others
+			sharedCtx, cancel := detach(ctx)
+			defer cancel()
+			res, err := oper.Handle(sharedCtx, req)
+			return dedupResult{res: res, staged: staged}, err
+		})
+		result := v.(dedupResult)
+		res = result.res
+		if shared && !ran && !isShareable(res) {
+			res, err = oper.Handle(ctx, req) //a download or stream can only be written once
+		} else if shared {
+			staged = result.staged //the headers set by the handler that ran
+			rlog.Debugf("shared %s result with identical concurrent requests", operName)
+		}
+	} else {
+		res, err = oper.Handle(ctx, req)
+	}
+	timing.observe(httpRes.Header(), "handle", handleStart)
+	clientClosed := httpReq.Context().Err() != nil //also cancelled the handler context
+	_, isLongPoll := oper.(LongPollOper)
+	pollExpired := isLongPoll && ctx.Err() == context.DeadlineExceeded && (err != nil || res == nil)
+	if breakerDone != nil {
+		//a request closed by the client says nothing about the health of the operation
+		breakerDone(!pollExpired && (ctx.Err() == context.DeadlineExceeded || (err != nil && errorCode(err, operErrorMapper(oper), s.config.ErrorMapper) >= 500)), !clientClosed)
+	}
+	if s.config.AfterHandle != nil {
+		s.config.AfterHandle(ctx, operName, res, err)
+	}
+	if clientClosed {
+		rlog.Infof("client closed the request before %s completed", operName)
+		if err != nil {
+			rlog.Debugf("%s error after the client closed the request: %+v", operName, err)
+			err = nil //nobody gets the error response, so it is neither written nor reported to OnServerError
+		}
+		httpRes.clientClosed()
+		return
+	}
+	staged.applyTo(httpRes.Header())
+	if pollExpired {
+		rlog.Debugf("%s poll timeout after %v", operName, timeout)
+		err = nil //the handler error reports the expired context
+		httpRes.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if ctx.Err() == context.DeadlineExceeded && !isLongPoll {
+		err = errors.Errorc(http.StatusGatewayTimeout, fmt.Sprintf("%s handler did not complete in %v", operName, timeout))
+		return
+	}
 	if err != nil {
+		if isRawBody && isMaxBytesError(err) {
+			err = errors.Errorc(http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", s.config.MaxBodyBytes))
+			return
+		}
 		err = errors.Wrapf(err, "%s handler failed", operName)
 		return
 	}
 
+	if s.config.CheckResponseTypes && res != nil {
+		if responseTyped, ok := oper.(ResponseTyped); ok && responseTyped.ResType() != nil && !resTypeMatches(res, responseTyped.ResType()) {
+			rlog.Errorf("%s returned %T instead of declared %v", operName, res, responseTyped.ResType())
+		}
+	}
+
+	if value := cacheControl(oper, res); value != "" {
+		httpRes.Header().Set("Cache-Control", value)
+	}
+
+	status := http.StatusOK
 	if res != nil {
-		var jsonRes []byte
-		jsonRes, err = json.Marshal(res)
-		httpRes.Header().Set("Content-Type", "application/json")
-		httpRes.Write(jsonRes)
+		if statusCoder, ok := res.(StatusCoder); ok && statusCoder.Status() != 0 {
+			status = statusCoder.Status()
+		}
+		if paginated, ok := res.(Paginated); ok && setPageHeaders(httpRes.Header(), httpReq, paginated.Page(), s.config.CursorParam) && status == http.StatusOK {
+			status = http.StatusPartialContent
+		}
+		if status == http.StatusNoContent || status == http.StatusNotModified {
+			httpRes.WriteHeader(status)
+			return
+		}
+		if download, ok := asDownload(res); ok {
+			if downloadErr := writeDownload(httpRes, httpReq, status, download); downloadErr != nil {
+				rlog.Errorf("failed to write %s download: %+v", operName, downloadErr)
+			}
+			return
+		}
+		if streamer, ok := asStreamer(ctx, res); ok {
+			if httpReq.Method == http.MethodHead {
+				httpRes.Header().Set("Content-Type", "application/x-ndjson")
+				httpRes.WriteHeader(status)
+				return
+			}
+			if streamErr := writeStream(httpRes, status, streamer); streamErr != nil {
+				rlog.Errorf("failed to stream %s response: %+v", operName, streamErr)
+			}
+			return
+		}
+		if resBody, err = encoder(res); err == ErrEncoderNotApplicable {
+			resContentType = "application/json"
+			resBody, err = s.encoders[resContentType](res)
+		}
+		if err != nil {
+			err = errors.Wrapf(err, "failed to encode %s response as %s", operName, resContentType)
+			return
+		}
+		if s.config.ValidateResponses && schemas.res != nil && resContentType == "application/json" {
+			if schemaErr := validateSchema(schemas.res, resBody); schemaErr != nil {
+				rlog.Errorf("%s response does not match its schema: %+v", operName, schemaErr)
+				err = errors.Errorc(http.StatusInternalServerError, fmt.Sprintf("%s response does not match its schema", operName))
+				return
+			}
+		}
+		compress := s.config.Gzip && len(resBody) >= s.config.GzipMinBytes && acceptsGzip(httpReq)
+		if s.config.Gzip {
+			httpRes.Header().Add("Vary", "Accept-Encoding") //also when not compressed, for caches
+		}
+		if s.config.ETag {
+			etag := etagFor(resBody, compress)
+			httpRes.Header().Set("ETag", etag)
+			if notModified(httpReq, etag) {
+				httpRes.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		//all headers must be set before WriteHeader()
+		httpRes.Header().Set("Content-Type", resContentType)
+		body := resBody
+		if compress {
+			if body, err = gzipBody(resBody); err != nil {
+				err = errors.Wrapf(err, "failed to compress %s response", operName)
+				return
+			}
+			httpRes.Header().Set("Content-Encoding", "gzip")
+		}
+		httpRes.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		httpRes.WriteHeader(status)
+		if httpReq.Method == http.MethodHead {
+			return //same headers as GET without the body
+		}
+		if _, writeErr := httpRes.Write(body); writeErr != nil {
+			rlog.Errorf("failed to write %s response: %+v", operName, writeErr)
+		}
+	} else {
+		//nil result has no body, unlike an empty struct which is encoded
+		status = http.StatusNoContent
+		httpRes.WriteHeader(status)
 	}
-	//http.Error(httpRes, "NYI", http.StatusNotFound)
 }
 
 func init() {
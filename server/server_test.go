@@ -0,0 +1,469 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+// testCtx is the ms.Context of the test micro-service
+type testCtx struct{ context.Context }
+
+// testOper is an operation with the given request type and handler
+type testOper struct {
+	reqType reflect.Type
+	handle  func(ctx ms.Context, req interface{}) (interface{}, error)
+}
+
+func (o testOper) ReqType() reflect.Type { return o.reqType }
+
+func (o testOper) Handle(ctx ms.Context, req interface{}) (interface{}, error) {
+	return o.handle(ctx, req)
+}
+
+// testMS is a micro-service with a fixed set of operations
+type testMS map[string]ms.Oper
+
+func (m testMS) Oper(name string) (ms.Oper, bool) {
+	oper, ok := m[name]
+	return oper, ok
+}
+
+func (m testMS) OperNames() []string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m testMS) NewContext() ms.Context { return testCtx{context.Background()} }
+
+// echo returns the request as the result
+func echo(ctx ms.Context, req interface{}) (interface{}, error) { return req, nil }
+
+// result returns a handler that always returns res
+func result(res interface{}) func(ms.Context, interface{}) (interface{}, error) {
+	return func(ms.Context, interface{}) (interface{}, error) { return res, nil }
+}
+
+// testHandler returns the handler of a server with config c for svc
+func testHandler(t *testing.T, c Config, svc testMS) http.Handler {
+	t.Helper()
+	if c.UnixSocket == "" && c.Addr == "" {
+		c.Addr = "localhost"
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("invalid config: %+v", err)
+	}
+	h, err := c.Handler(svc)
+	if err != nil {
+		t.Fatalf("failed to create handler: %+v", err)
+	}
+	return h
+}
+
+// do serves a request with the header name/value pairs and returns the response
+func do(h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	httpReq := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		httpReq.Header.Set(header[i], header[i+1])
+	}
+	httpRes := httptest.NewRecorder()
+	h.ServeHTTP(httpRes, httpReq)
+	return httpRes
+}
+
+// startServer serves svc on a random port until the test ends
+func startServer(t *testing.T, c Config, svc testMS) (*server, string) {
+	t.Helper()
+	c.Addr, c.Port = "127.0.0.1", 0
+	if err := c.Validate(); err != nil {
+		t.Fatalf("invalid config: %+v", err)
+	}
+	msServer, err := c.Create(svc)
+	if err != nil {
+		t.Fatalf("failed to create server: %+v", err)
+	}
+	s := msServer.(*server)
+	listening := make(chan net.Addr, 1)
+	s.config.OnListen = func(addr net.Addr) { listening <- addr }
+	served := make(chan error, 1)
+	go func() { served <- s.Serve() }()
+	select {
+	case addr := <-listening:
+		t.Cleanup(func() {
+			s.Shutdown(context.Background())
+			<-served
+		})
+		return s, "http://" + addr.String()
+	case err := <-served:
+		t.Fatalf("failed to serve: %+v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not listen")
+	}
+	return nil, ""
+}
+
+func TestShutdownWaitsForRequest(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := Config{Addr: "127.0.0.1"}
+	msServer, err := c.Create(testMS{"slow": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return "done", nil
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := msServer.(*server)
+	listening := make(chan net.Addr, 1)
+	s.config.OnListen = func(addr net.Addr) { listening <- addr }
+	served := make(chan error, 1)
+	go func() { served <- s.Serve() }()
+	addr := <-listening
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		httpRes, err := http.Get("http://" + addr.String() + "/slow")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer httpRes.Body.Close()
+		body, err := io.ReadAll(httpRes.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+	<-started
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v before the request completed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if res := <-responses; res.err != nil || res.body != `"done"` {
+		t.Fatalf("in-flight request got %q, %v", res.body, res.err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown failed: %+v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve returned %+v after Shutdown, expected nil", err)
+	}
+}
+
+func TestWriteTimeoutClosesConnection(t *testing.T) {
+	_, url := startServer(t, Config{WriteTimeout: 50 * time.Millisecond}, testMS{
+		"slow": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			time.Sleep(150 * time.Millisecond)
+			return "late", nil
+		}},
+		"fast": testOper{handle: result("ok")},
+	})
+	if httpRes, err := http.Get(url + "/fast"); err != nil || httpRes.StatusCode != http.StatusOK {
+		t.Fatalf("fast request failed: %v", err)
+	} else {
+		httpRes.Body.Close()
+	}
+	if httpRes, err := http.Get(url + "/slow"); err == nil {
+		body, _ := io.ReadAll(httpRes.Body)
+		httpRes.Body.Close()
+		t.Fatalf("expected the connection to be closed, got %d %q", httpRes.StatusCode, body)
+	}
+}
+
+func TestValidateTimeouts(t *testing.T) {
+	for name, c := range map[string]Config{
+		"read":  {Addr: "localhost", ReadTimeout: -time.Second},
+		"write": {Addr: "localhost", WriteTimeout: -time.Second},
+		"idle":  {Addr: "localhost", IdleTimeout: -time.Second},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("negative %s timeout accepted", name)
+		}
+	}
+	if err := (Config{Addr: "localhost"}).Validate(); err != nil {
+		t.Fatalf("zero timeouts rejected: %+v", err)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	svc := testMS{"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo}}
+	body := `{"name":"` + strings.Repeat("a", 2<<20) + `"}`
+	if w := do(testHandler(t, Config{}, svc), http.MethodPost, "/create", body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("default limit got %d", w.Code)
+	}
+	h := testHandler(t, Config{MaxBodyBytes: 16}, svc)
+	if w := do(h, http.MethodPost, "/create", `{"name":"a long name"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("configured limit got %d", w.Code)
+	}
+	if w := do(h, http.MethodPost, "/create", `{"name":"a"}`); w.Code != http.StatusOK {
+		t.Fatalf("small body got %d %s", w.Code, w.Body)
+	}
+	if w := do(testHandler(t, Config{MaxBodyBytes: -1}, svc), http.MethodPost, "/create", body); w.Code != http.StatusOK {
+		t.Fatalf("unlimited got %d", w.Code)
+	}
+}
+
+func TestNilResult(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{
+		"nil":   testOper{handle: result(nil)},
+		"empty": testOper{handle: result(struct{}{})},
+	})
+	if w := do(h, http.MethodDelete, "/nil", ""); w.Code != http.StatusNoContent || w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Fatalf("nil result got %d %q %v", w.Code, w.Body, w.Header())
+	}
+	if w := do(h, http.MethodDelete, "/empty", ""); w.Code != http.StatusOK || w.Body.String() != `{}` {
+		t.Fatalf("empty struct got %d %q", w.Code, w.Body)
+	}
+}
+
+// logRecorder records the log lines of a server
+type logRecorder struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *logRecorder) record(level, format string, args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *logRecorder) Debugf(format string, args ...interface{}) { l.record("DEBUG", format, args...) }
+func (l *logRecorder) Infof(format string, args ...interface{})  { l.record("INFO", format, args...) }
+func (l *logRecorder) Errorf(format string, args ...interface{}) { l.record("ERROR", format, args...) }
+
+// contains returns true if a line contains s
+func (l *logRecorder) contains(s string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// loggedHandler is like testHandler with the server logging to the returned recorder
+func loggedHandler(t *testing.T, c Config, svc testMS) (http.Handler, *logRecorder) {
+	t.Helper()
+	c.Addr = "localhost"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("invalid config: %+v", err)
+	}
+	s, err := c.newServer(svc)
+	if err != nil {
+		t.Fatalf("failed to create server: %+v", err)
+	}
+	rec := &logRecorder{}
+	s.log = rec
+	s.listening.Store(true)
+	return s.httpServer.Handler, rec
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	h := testHandler(t, Config{MaxConcurrentRequests: 2}, testMS{
+		"slow": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			started <- struct{}{}
+			<-release
+			return "done", nil
+		}},
+		"panic": testOper{handle: func(ms.Context, interface{}) (interface{}, error) { panic("boom") }},
+	})
+	//panics release their slot
+	for i := 0; i < 3; i++ {
+		if w := do(h, http.MethodGet, "/panic", ""); w.Code != http.StatusInternalServerError {
+			t.Fatalf("panic %d got %d", i, w.Code)
+		}
+	}
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- do(h, http.MethodGet, "/slow", "").Code }()
+		<-started
+	}
+	w := do(h, http.MethodGet, "/slow", "")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("saturated got %d %v", w.Code, w.Header())
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Fatalf("blocked request got %d", code)
+		}
+	}
+	if w := do(h, http.MethodGet, "/slow", ""); w.Code != http.StatusOK {
+		t.Fatalf("after release got %d", w.Code)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handle  time.Duration
+		drained bool
+	}{
+		{"within", 20 * time.Millisecond, true},
+		{"exceeded", 2 * time.Second, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			started := make(chan struct{})
+			s, url := startServer(t, Config{ShutdownTimeout: 200 * time.Millisecond}, testMS{
+				"slow": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+					close(started)
+					time.Sleep(tc.handle)
+					return "done", nil
+				}},
+			})
+			responses := make(chan error, 1)
+			go func() {
+				httpRes, err := http.Get(url + "/slow")
+				if err == nil {
+					_, err = io.ReadAll(httpRes.Body)
+					httpRes.Body.Close()
+				}
+				responses <- err
+			}()
+			<-started
+			shutdown := make(chan error, 1)
+			go func() { shutdown <- s.Shutdown(context.Background()) }()
+			for !s.draining.Load() {
+				time.Sleep(time.Millisecond)
+			}
+			h := s.httpServer.Handler
+			if w := do(h, http.MethodGet, "/readyz", ""); w.Code != http.StatusServiceUnavailable {
+				t.Errorf("ready while draining got %d", w.Code)
+			}
+			if w := do(h, http.MethodGet, "/slow", ""); w.Code != http.StatusServiceUnavailable {
+				t.Errorf("new request while draining got %d", w.Code)
+			}
+			if err := <-shutdown; (err == nil) != tc.drained {
+				t.Fatalf("Shutdown returned %v", err)
+			}
+			if err := <-responses; (err == nil) != tc.drained {
+				t.Fatalf("in-flight request returned %v", err)
+			}
+		})
+	}
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+	_, url := startServer(t, Config{MaxHeaderBytes: 1024}, testMS{"get": testOper{handle: result("ok")}})
+	for size, want := range map[int]int{
+		512:   http.StatusOK,
+		16384: http.StatusRequestHeaderFieldsTooLarge, //net/http allows 4096 bytes above the limit
+	} {
+		httpReq, _ := http.NewRequest(http.MethodGet, url+"/get", nil)
+		httpReq.Header.Set("X-Big", strings.Repeat("x", size))
+		httpRes, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatalf("%d byte header failed: %+v", size, err)
+		}
+		httpRes.Body.Close()
+		if httpRes.StatusCode != want {
+			t.Errorf("%d byte header got %d, expected %d", size, httpRes.StatusCode, want)
+		}
+	}
+	if err := (Config{Addr: "localhost", MaxHeaderBytes: -1}).Validate(); err == nil {
+		t.Fatal("negative maxHeaderBytes accepted")
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	large := strings.Repeat("x", 100<<10) //above the net/http buffer that would switch to chunked
+	svr := httptest.NewServer(testHandler(t, Config{Gzip: true}, testMS{
+		"large": testOper{handle: result(large)},
+		"fail":  testOper{handle: func(ms.Context, interface{}) (interface{}, error) { return nil, fmt.Errorf("failed") }},
+		"nil":   testOper{handle: result(nil)},
+	}))
+	defer svr.Close()
+	transport := &http.Transport{DisableCompression: true}
+	defer transport.CloseIdleConnections()
+	for _, tc := range []struct {
+		path, encoding string
+		want           int
+	}{
+		{"/large", "", http.StatusOK},
+		{"/large", "gzip", http.StatusOK},
+		{"/fail", "", http.StatusInternalServerError},
+		{"/nil", "", http.StatusNoContent},
+	} {
+		httpReq, _ := http.NewRequest(http.MethodGet, svr.URL+tc.path, nil)
+		if tc.encoding != "" {
+			httpReq.Header.Set("Accept-Encoding", tc.encoding)
+		}
+		httpRes, err := transport.RoundTrip(httpReq)
+		if err != nil {
+			t.Fatalf("%s failed: %+v", tc.path, err)
+		}
+		body, _ := io.ReadAll(httpRes.Body)
+		httpRes.Body.Close()
+		if httpRes.StatusCode != tc.want || len(httpRes.TransferEncoding) != 0 || httpRes.Header.Get("Content-Encoding") != tc.encoding {
+			t.Fatalf("%s %s got %d %v %q", tc.path, tc.encoding, httpRes.StatusCode, httpRes.TransferEncoding, httpRes.Header.Get("Content-Encoding"))
+		}
+		if tc.want == http.StatusNoContent {
+			if httpRes.Header.Get("Content-Length") != "" || len(body) != 0 {
+				t.Fatalf("204 got Content-Length %q and %d bytes", httpRes.Header.Get("Content-Length"), len(body))
+			}
+		} else if httpRes.ContentLength != int64(len(body)) {
+			t.Fatalf("%s %s Content-Length %d for %d bytes", tc.path, tc.encoding, httpRes.ContentLength, len(body))
+		}
+	}
+}
+
+func TestDisableKeepAlives(t *testing.T) {
+	svc := testMS{"get": testOper{handle: result("ok")}}
+	for _, disabled := range []bool{false, true} {
+		_, url := startServer(t, Config{DisableKeepAlives: disabled}, svc)
+		httpRes, err := http.Get(url + "/get")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(httpRes.Body)
+		httpRes.Body.Close()
+		if httpRes.Close != disabled { //set by "Connection: close"
+			t.Fatalf("disableKeepAlives:%v got close:%v", disabled, httpRes.Close)
+		}
+	}
+	if err := (Config{Addr: "localhost", DisableKeepAlives: true, IdleTimeout: time.Second}).Validate(); err == nil {
+		t.Fatal("idleTimeout accepted without keep-alives")
+	}
+}
+
+func TestMaxPathLength(t *testing.T) {
+	h := testHandler(t, Config{MaxPathLength: 16}, testMS{"ping": testOper{handle: result("pong")}})
+	for target, want := range map[string]int{
+		"/ping":                              http.StatusOK,
+		"/ping?q=" + strings.Repeat("a", 64): http.StatusOK, //the query is not limited
+		"/" + strings.Repeat("a", 15):        http.StatusNotFound,
+		"/" + strings.Repeat("a", 16):        http.StatusRequestURITooLong,
+		"/%20%20%20%20%20%20":                http.StatusRequestURITooLong, //the escaped path is limited
+	} {
+		if w := do(h, http.MethodGet, target, ""); w.Code != want {
+			t.Errorf("%s got %d, want %d", target, w.Code, want)
+		}
+	}
+	if err := (Config{Addr: "localhost", MaxPathLength: -1}).Validate(); err == nil {
+		t.Fatal("negative maxPathLength accepted")
+	}
+}
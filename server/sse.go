@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+// defaultSSEKeepAlive is used when Config.SSEKeepAlive is not set
+const defaultSSEKeepAlive = 15 * time.Second
+
+// Event is a server-sent event
+// Data is sent as is when it is a string, else encoded as JSON
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// EventStreamOper is optionally implemented by an operation to push server-sent events
+// instead of returning a result, the response is text/event-stream
+// StreamEvents sends events until it returns or ctx is done when the client disconnects,
+// the server closes events after StreamEvents returned
+type EventStreamOper interface {
+	StreamEvents(ctx ms.Context, req interface{}, events chan<- Event) error
+}
+
+// serveEvents runs the operation and writes its events with a keep-alive comment
+// when nothing was sent for Config.SSEKeepAlive
+// headers are sent before the operation runs so errors are sent as an "error" event
+func (s *server) serveEvents(httpRes http.ResponseWriter, httpReq *http.Request, ctx ms.Context, req interface{}, oper ms.Oper, operName string, rlog requestLogger) {
+	httpRes.Header().Set("Content-Type", "text/event-stream")
+	httpRes.Header().Set("Cache-Control", "no-cache")
+	httpRes.Header().Set("X-Accel-Buffering", "no") //disable proxy buffering, e.g. nginx
+	httpRes.WriteHeader(http.StatusOK)
+	flusher, _ := httpRes.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	events := make(chan Event)
+	done := make(chan error, 1)
+	go func() {
+		defer close(events)
+		done <- oper.(EventStreamOper).StreamEvents(ctx, req, events)
+	}()
+
+	keepAlive := time.NewTicker(s.config.SSEKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				if err := <-done; err != nil {
+					rlog.Errorf("%s event stream failed: %+v", operName, err)
+					code := errorCode(err, operErrorMapper(oper), s.config.ErrorMapper)
+					writeEvent(httpRes, Event{Event: "error", Data: ErrorInfo{Code: code, ErrorCode: ErrorCodeOf(err, code), Message: s.exposedError(err, code).Error()}})
+					flush()
+				}
+				return
+			}
+			if err := writeEvent(httpRes, event); err != nil {
+				rlog.Errorf("failed to write %s event: %+v", operName, err)
+				drainEvents(events)
+				return
+			}
+			flush()
+			keepAlive.Reset(s.config.SSEKeepAlive)
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(httpRes, ": keep-alive\n\n"); err != nil {
+				drainEvents(events)
+				return
+			}
+			flush()
+		case <-ctx.Done():
+			rlog.Debugf("%s event stream closed by client", operName)
+			drainEvents(events)
+			return
+		}
+	}
+}
+
+// drainEvents discards events until the operation returns, so that it does not block on a send
+// operations should stop when ctx is done
+func drainEvents(events <-chan Event) {
+	go func() {
+		for range events {
+		}
+	}()
+}
+
+// writeEvent writes the event with data: lines for each line of the data
+func writeEvent(httpRes http.ResponseWriter, event Event) error {
+	var data string
+	switch d := event.Data.(type) {
+	case string:
+		data = d
+	case nil:
+	default:
+		jsonData, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(jsonData)
+	}
+	var b strings.Builder
+	if event.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", event.ID)
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event.Event)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := fmt.Fprint(httpRes, b.String())
+	return err
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+// tickOper sends numbered tick events until the client disconnects
+type tickOper struct {
+	testOper
+	cancelled chan struct{}
+}
+
+func (o tickOper) StreamEvents(ctx ms.Context, req interface{}, events chan<- Event) error {
+	for i := 0; ; i++ {
+		select {
+		case events <- Event{ID: strconv.Itoa(i), Event: "tick", Data: map[string]int{"n": i}}:
+			time.Sleep(5 * time.Millisecond)
+		case <-ctx.Done():
+			close(o.cancelled)
+			return nil
+		}
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	oper := tickOper{cancelled: make(chan struct{})}
+	svr := httptest.NewServer(testHandler(t, Config{SSEKeepAlive: 5 * time.Millisecond}, testMS{"ticks": oper}))
+	defer svr.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	httpReq, _ := http.NewRequestWithContext(ctx, http.MethodGet, svr.URL+"/ticks", nil)
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpRes, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if httpRes.Header.Get("Content-Type") != "text/event-stream" || httpRes.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("headers %v", httpRes.Header)
+	}
+	lines := []string{}
+	scanner := bufio.NewScanner(httpRes.Body)
+	for len(lines) < 6 && scanner.Scan() {
+		if line := scanner.Text(); line != "" && !strings.HasPrefix(line, ":") { //skip separators and keep-alive comments
+			lines = append(lines, line)
+		}
+	}
+	want := []string{"id: 0", "event: tick", `data: {"n":0}`, "id: 1", "event: tick", `data: {"n":1}`}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got events %q", lines)
+	}
+	cancel()
+	httpRes.Body.Close()
+	select {
+	case <-oper.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not cancelled when the client disconnected")
+	}
+}
+
+func TestServerSentEventsDryRun(t *testing.T) {
+	h := testHandler(t, Config{}, testMS{"ticks": tickOper{cancelled: make(chan struct{})}})
+	w := do(h, http.MethodGet, "/ticks", "", "Accept", "text/event-stream", DryRunHeader, "true")
+	var res DryRunResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || w.Code != http.StatusOK || !res.Valid || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("dry run got %d %s %v", w.Code, w.Body, w.Header())
+	}
+}
+
+// failingEventsOper fails before sending events
+type failingEventsOper struct {
+	mappedOper
+	err error
+}
+
+func (o failingEventsOper) StreamEvents(ctx ms.Context, req interface{}, events chan<- Event) error {
+	return o.err
+}
+
+func TestServerSentEventsError(t *testing.T) {
+	h := testHandler(t, Config{ErrorMapper: func(err error) int {
+		if err == errNoSuchUser {
+			return http.StatusNotFound
+		}
+		return 0
+	}}, testMS{
+		"locked":  failingEventsOper{err: errLocked},
+		"missing": failingEventsOper{err: errNoSuchUser},
+	})
+	for operName, want := range map[string]string{"locked": `"code":423`, "missing": `"code":404`} {
+		w := do(h, http.MethodGet, "/"+operName, "", "Accept", "text/event-stream")
+		if !strings.Contains(w.Body.String(), "event: error") || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s got %s, expected %s", operName, w.Body, want)
+		}
+	}
+}
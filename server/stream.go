@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// Streamer is optionally implemented by an operation result to stream records
+// to the client as newline-delimited JSON instead of encoding the result at once
+// Stream must call emit for each record and stop when emit returns an error.
+// A result that is a receive channel is streamed the same way until it is closed.
+type Streamer interface {
+	Stream(emit func(record interface{}) error) error
+}
+
+// chanStreamer streams the values received from a channel
+type chanStreamer struct {
+	ctx context.Context
+	ch  reflect.Value
+}
+
+func (c chanStreamer) Stream(emit func(record interface{}) error) error {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: c.ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ctx.Done())},
+	}
+	for {
+		chosen, v, ok := reflect.Select(cases)
+		if chosen == 1 {
+			return c.ctx.Err()
+		}
+		if !ok {
+			return nil //closed
+		}
+		if err := emit(v.Interface()); err != nil {
+			return err
+		}
+	}
+}
+
+// asStreamer returns the streamer for results that must be streamed
+func asStreamer(ctx context.Context, res interface{}) (Streamer, bool) {
+	if streamer, ok := res.(Streamer); ok {
+		return streamer, true
+	}
+	if v := reflect.ValueOf(res); v.Kind() == reflect.Chan && v.Type().ChanDir()&reflect.RecvDir != 0 {
+		return chanStreamer{ctx: ctx, ch: v}, true
+	}
+	return nil, false
+}
+
+// writeStream writes the streamed records with flushing
+// headers are sent before the first record so errors can only be logged
+func writeStream(httpRes http.ResponseWriter, status int, streamer Streamer) error {
+	httpRes.Header().Set("Content-Type", "application/x-ndjson")
+	httpRes.Header().Set("Transfer-Encoding", "chunked")
+	httpRes.WriteHeader(status)
+	flusher, _ := httpRes.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	encoder := json.NewEncoder(httpRes) //writes a newline after each record
+	return streamer.Stream(func(record interface{}) error {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
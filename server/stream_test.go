@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-msvc/errors"
+)
+
+type record struct {
+	N int `json:"n"`
+}
+
+// records streams n records then fails with err when not nil
+type records struct {
+	n   int
+	err error
+}
+
+func (r records) Stream(emit func(record interface{}) error) error {
+	for i := 1; i <= r.n; i++ {
+		if err := emit(record{N: i}); err != nil {
+			return err
+		}
+	}
+	return r.err
+}
+
+func TestStreamedResponse(t *testing.T) {
+	ch := make(chan record)
+	go func() {
+		for i := 1; i <= 3; i++ {
+			ch <- record{N: i}
+		}
+		close(ch)
+	}()
+	_, url := startServer(t, Config{}, testMS{
+		"streamer": testOper{handle: result(records{n: 3})},
+		"chan":     testOper{handle: result((<-chan record)(ch))},
+		"failing":  testOper{handle: result(records{n: 2, err: errors.Error("downstream failed")})},
+	})
+	for operName, count := range map[string]int{"streamer": 3, "chan": 3, "failing": 2} {
+		httpRes, err := http.Get(url + "/" + operName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if httpRes.StatusCode != http.StatusOK || httpRes.Header.Get("Content-Type") != "application/x-ndjson" || len(httpRes.TransferEncoding) == 0 {
+			t.Fatalf("%s got %d %v %v", operName, httpRes.StatusCode, httpRes.Header, httpRes.TransferEncoding)
+		}
+		scanner := bufio.NewScanner(httpRes.Body)
+		n := 0
+		for scanner.Scan() {
+			var r record
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.N != n+1 {
+				t.Fatalf("%s record %d: %q %v", operName, n, scanner.Text(), err)
+			}
+			n++
+		}
+		httpRes.Body.Close()
+		if n != count {
+			t.Fatalf("%s streamed %d records, expected %d", operName, n, count)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+// Client timeout headers, Request-Timeout holds seconds or a duration like "1500ms"
+// and X-Timeout-Ms holds milliseconds
+const (
+	RequestTimeoutHeader = "Request-Timeout"
+	TimeoutMsHeader      = "X-Timeout-Ms"
+)
+
+const defaultPollTimeout = 30 * time.Second
+
+// clientTimeout returns the positive timeout requested by the client,
+// or 0 when no timeout header is present
+func clientTimeout(httpReq *http.Request) (time.Duration, error) {
+	if value := strings.TrimSpace(httpReq.Header.Get(RequestTimeoutHeader)); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			if seconds <= 0 || seconds > float64(1<<62)/float64(time.Second) {
+				return 0, errors.Errorf("%s:%q out of range", RequestTimeoutHeader, value)
+			}
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return 0, errors.Errorf("invalid %s:%q", RequestTimeoutHeader, value)
+		}
+		return d, nil
+	}
+	if value := strings.TrimSpace(httpReq.Header.Get(TimeoutMsHeader)); value != "" {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ms <= 0 || ms > int64(1<<62)/int64(time.Millisecond) {
+			return 0, errors.Errorf("invalid %s:%q", TimeoutMsHeader, value)
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	return 0, nil
+}
+
+// handlerTimeout returns the time the handler may run for httpReq, 0 means no limit
+// client timeouts are capped at MaxClientTimeout and cannot extend the HandlerTimeout,
+// the timeout of an operation implementing TimeoutOper or the poll timeout of a LongPollOper
+func (s *server) handlerTimeout(httpReq *http.Request, oper ms.Oper, rlog requestLogger) time.Duration {
+	timeout := s.config.HandlerTimeout
+	if timeoutOper, ok := oper.(TimeoutOper); ok && timeoutOper.Timeout() > 0 {
+		timeout = timeoutOper.Timeout()
+	}
+	if pollOper, ok := oper.(LongPollOper); ok {
+		timeout = s.config.PollTimeout
+		if pollOper.PollTimeout() > 0 {
+			timeout = pollOper.PollTimeout()
+		}
+	}
+	if s.config.MaxClientTimeout <= 0 {
+		return timeout
+	}
+	requested, err := clientTimeout(httpReq)
+	if err != nil {
+		rlog.Debugf("ignoring client timeout: %+v", err)
+		return timeout
+	}
+	if requested == 0 {
+		return timeout
+	}
+	if requested > s.config.MaxClientTimeout {
+		requested = s.config.MaxClientTimeout
+	}
+	if timeout == 0 || requested < timeout {
+		timeout = requested
+	}
+	return timeout
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	cancelled := make(chan error, 1)
+	h := testHandler(t, Config{HandlerTimeout: 20 * time.Millisecond}, testMS{
+		"slow": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			select {
+			case <-ctx.Done():
+				cancelled <- ctx.Err()
+				return nil, ctx.Err()
+			case <-time.After(time.Second):
+				return "late", nil
+			}
+		}},
+	})
+	start := time.Now()
+	w := do(h, http.MethodGet, "/slow", "")
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("took %v", elapsed)
+	}
+	select {
+	case err := <-cancelled:
+		if err == nil {
+			t.Fatal("handler context not cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not see the deadline")
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	h, log := loggedHandler(t, Config{MaxClientTimeout: time.Minute, HandlerTimeout: time.Hour}, testMS{
+		"deadline": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			deadline, _ := ctx.Deadline()
+			deadlines <- time.Until(deadline)
+			return nil, nil
+		}},
+		"slow": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}},
+	})
+	for name, test := range map[string]struct {
+		header   []string
+		min, max time.Duration
+	}{
+		"milliseconds":   {[]string{TimeoutMsHeader, "500"}, 0, 500 * time.Millisecond},
+		"seconds":        {[]string{RequestTimeoutHeader, "2"}, time.Second, 2 * time.Second},
+		"duration":       {[]string{RequestTimeoutHeader, "1500ms"}, time.Second, 1500 * time.Millisecond},
+		"capped":         {[]string{RequestTimeoutHeader, "3600"}, 59 * time.Second, time.Minute},
+		"malformed":      {[]string{RequestTimeoutHeader, "soon"}, 59 * time.Minute, time.Hour},
+		"no header":      {nil, 59 * time.Minute, time.Hour},
+		"negative value": {[]string{TimeoutMsHeader, "-5"}, 59 * time.Minute, time.Hour},
+	} {
+		do(h, http.MethodGet, "/deadline", "", test.header...)
+		if d := <-deadlines; d <= test.min || d > test.max {
+			t.Errorf("%s deadline in %v, expected %v..%v", name, d, test.min, test.max)
+		}
+	}
+	if !log.contains(`ignoring client timeout: invalid Request-Timeout:"soon"`) {
+		t.Fatalf("malformed header not logged: %v", log.lines)
+	}
+	if w := do(h, http.MethodGet, "/slow", "", TimeoutMsHeader, "20"); w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expired got %d", w.Code)
+	}
+}
+
+// timeoutOper overrides the handler timeout
+type timeoutOper struct {
+	testOper
+	timeout time.Duration
+}
+
+func (o timeoutOper) Timeout() time.Duration { return o.timeout }
+
+func TestOperationTimeout(t *testing.T) {
+	sleep := func(d time.Duration) testOper {
+		return testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			select {
+			case <-time.After(d):
+				return "done", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}}
+	}
+	h := testHandler(t, Config{HandlerTimeout: 20 * time.Millisecond}, testMS{
+		"global":  sleep(100 * time.Millisecond),
+		"report":  timeoutOper{sleep(100 * time.Millisecond), time.Second},
+		"tooLong": timeoutOper{sleep(time.Second), 50 * time.Millisecond},
+		"shorter": timeoutOper{sleep(10 * time.Millisecond), 5 * time.Millisecond},
+		"zero":    timeoutOper{sleep(100 * time.Millisecond), 0},
+	})
+	for path, want := range map[string]int{
+		"/global":  http.StatusGatewayTimeout,
+		"/report":  http.StatusOK,
+		"/tooLong": http.StatusGatewayTimeout,
+		"/shorter": http.StatusGatewayTimeout,
+		"/zero":    http.StatusGatewayTimeout,
+	} {
+		if w := do(h, http.MethodGet, path, ""); w.Code != want {
+			t.Errorf("%s got %d, expected %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestClientClosed(t *testing.T) {
+	var sink bytes.Buffer
+	metrics := NewPrometheusMetrics()
+	reported := 0
+	waitForClose := func(res interface{}, err error) testOper {
+		return testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return res, err
+		}}
+	}
+	h := testHandler(t, Config{
+		AccessLog:       true,
+		AccessLogWriter: &sink,
+		Metrics:         metrics,
+		ErrorWriter: func(http.ResponseWriter, *http.Request, int, error) {
+			t.Error("error written after the client closed the request")
+		},
+		OnServerError: func(*http.Request, string, error, int) { reported++ },
+	}, testMS{
+		"slow":    waitForClose("late", nil),
+		"failing": waitForClose(nil, errors.Error("downstream call cancelled")),
+	})
+
+	for _, operName := range []string{"slow", "failing"} {
+		reqCtx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+operName, nil).WithContext(reqCtx))
+		if w.Body.Len() != 0 {
+			t.Errorf("%s wrote %s after the client closed the request", operName, w.Body)
+		}
+		if count := metrics.Count(operName, StatusClientClosedRequest); count != 1 {
+			t.Errorf("%s counted %d closed requests", operName, count)
+		}
+	}
+	if reported != 0 {
+		t.Fatalf("%d errors of closed requests reported", reported)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(sink.Bytes()), []byte("\n")) {
+		var entry accessLogEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.Status != StatusClientClosedRequest {
+			t.Fatalf("logged %s: %v", line, err)
+		}
+	}
+}
+
+// pollOper waits for events
+type pollOper struct {
+	testOper
+	timeout time.Duration
+}
+
+func (o pollOper) PollTimeout() time.Duration { return o.timeout }
+
+func TestLongPoll(t *testing.T) {
+	events := make(chan string, 1)
+	wait := func(ctx ms.Context, req interface{}) (interface{}, error) {
+		select {
+		case event := <-events:
+			return map[string]string{"event": event}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	h := testHandler(t, Config{HandlerTimeout: time.Millisecond, PollTimeout: 50 * time.Millisecond}, testMS{
+		"poll":  pollOper{testOper{handle: wait}, 0},
+		"quick": pollOper{testOper{handle: wait}, 10 * time.Millisecond},
+	})
+
+	time.AfterFunc(20*time.Millisecond, func() { events <- "created" })
+	if w := do(h, http.MethodGet, "/poll", ""); w.Code != http.StatusOK || w.Body.String() != `{"event":"created"}` {
+		t.Fatalf("event got %d %s", w.Code, w.Body)
+	}
+
+	start := time.Now()
+	w := do(h, http.MethodGet, "/poll", "")
+	if elapsed := time.Since(start); w.Code != http.StatusNoContent || w.Body.Len() != 0 || elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("expired poll got %d %s after %v", w.Code, w.Body, elapsed)
+	}
+	start = time.Now()
+	if w := do(h, http.MethodGet, "/quick", ""); w.Code != http.StatusNoContent || time.Since(start) > 40*time.Millisecond {
+		t.Fatalf("operation poll timeout got %d after %v", w.Code, time.Since(start))
+	}
+	if err := (Config{Addr: "localhost", PollTimeout: -time.Second}).Validate(); err == nil {
+		t.Fatal("negative pollTimeout accepted")
+	}
+}
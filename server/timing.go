@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ServerTimingHeader reports the duration of request phases when Config.Debug is set
+const ServerTimingHeader = "Server-Timing"
+
+// serverTiming collects phase durations for the Server-Timing header
+// a nil serverTiming records nothing
+type serverTiming struct {
+	names     []string
+	durations map[string]time.Duration
+}
+
+func newServerTiming() *serverTiming {
+	return &serverTiming{durations: map[string]time.Duration{}}
+}
+
+// observe adds the time since start to the phase and updates the header,
+// so that the phases before an error are also reported
+// headers set after the response was started are not sent
+func (t *serverTiming) observe(header http.Header, name string, start time.Time) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.durations[name]; !ok {
+		t.names = append(t.names, name)
+	}
+	t.durations[name] += time.Since(start)
+	header.Set(ServerTimingHeader, t.String())
+}
+
+// String formats the phases like "decode;dur=1.2, handle;dur=45.6" in milliseconds
+func (t *serverTiming) String() string {
+	metrics := make([]string, len(t.names))
+	for i, name := range t.names {
+		metrics[i] = fmt.Sprintf("%s;dur=%.1f", name, float64(t.durations[name].Microseconds())/1000)
+	}
+	return strings.Join(metrics, ", ")
+}
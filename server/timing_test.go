@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestServerTiming(t *testing.T) {
+	svc := testMS{"create": testOper{reqType: reflect.TypeOf(validatedReq{}), handle: echo}}
+	metric := regexp.MustCompile(`^(decode|validate|handle);dur=\d+\.\d$`)
+	names := func(header string) []string {
+		var names []string
+		for _, m := range strings.Split(header, ", ") {
+			if !metric.MatchString(m) {
+				t.Fatalf("malformed metric %q in %q", m, header)
+			}
+			names = append(names, metric.FindStringSubmatch(m)[1])
+		}
+		return names
+	}
+
+	h := testHandler(t, Config{Debug: true}, svc)
+	w := do(h, http.MethodPost, "/create", `{"name":"a"}`)
+	if got := names(w.Header().Get(ServerTimingHeader)); w.Code != http.StatusOK || !reflect.DeepEqual(got, []string{"decode", "validate", "handle"}) {
+		t.Fatalf("got %d with phases %v", w.Code, got)
+	}
+	//the phases before an error are still reported
+	w = do(h, http.MethodPost, "/create", `{}`)
+	if got := names(w.Header().Get(ServerTimingHeader)); w.Code != http.StatusBadRequest || !reflect.DeepEqual(got, []string{"decode"}) {
+		t.Fatalf("invalid request got %d with phases %v", w.Code, got)
+	}
+
+	if w := do(testHandler(t, Config{}, svc), http.MethodPost, "/create", `{"name":"a"}`); w.Header().Get(ServerTimingHeader) != "" {
+		t.Fatalf("got %s without debug", w.Header().Get(ServerTimingHeader))
+	}
+}
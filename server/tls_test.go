@@ -0,0 +1,142 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/ms"
+)
+
+// testCert is a certificate signed by parent, or self-signed when parent is nil
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// files writes the PEM certificate and key into dir and returns their names
+func (c *testCert) files(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil)
+	caFile, _ := ca.files(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "127.0.0.1", ca).files(t, dir, "server")
+	subject := testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		s, _ := ClientCertSubject(ctx)
+		return s, nil
+	}}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	_, url := startServer(t, Config{CertFile: certFile, KeyFile: keyFile}, testMS{"subject": subject})
+	url = "https" + url[len("http"):]
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if body := get(t, client, url+"/subject"); body != `""` {
+		t.Fatalf("got %s without a client certificate", body)
+	}
+
+	_, url = startServer(t, Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, testMS{"subject": subject})
+	url = "https" + url[len("http"):]
+	if httpRes, err := client.Get(url + "/subject"); err == nil {
+		httpRes.Body.Close()
+		t.Fatal("request without a client certificate accepted")
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{newTestCert(t, "billing", ca).tlsCert()},
+	}}}
+	if body := get(t, client, url+"/subject"); body != `"CN=billing"` {
+		t.Fatalf("got %s", body)
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	for name, c := range map[string]Config{
+		"cert only":      {Addr: "localhost", CertFile: "server.crt"},
+		"key only":       {Addr: "localhost", KeyFile: "server.key"},
+		"client ca only": {Addr: "localhost", ClientCAFile: "ca.crt"},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+// get returns the body of a successful GET request
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	httpRes, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %+v", url, err)
+	}
+	defer httpRes.Body.Close()
+	body, err := io.ReadAll(httpRes.Body)
+	if err != nil || httpRes.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s got %d %q: %v", url, httpRes.StatusCode, body, err)
+	}
+	return string(body)
+}
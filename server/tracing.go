@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans created by this package
+const tracerName = "github.com/go-msvc/http/server"
+
+// startSpan starts a server span for httpReq, continuing the trace of an incoming
+// traceparent header, and returns the request with the span in its context
+// the span is renamed to the operation once it is known
+func (s *server) startSpan(httpReq *http.Request) (*http.Request, trace.Span) {
+	ctx := s.config.Propagator.Extract(httpReq.Context(), propagation.HeaderCarrier(httpReq.Header))
+	ctx, span := s.tracer.Start(ctx, "HTTP "+httpReq.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", httpReq.Method),
+			attribute.String("http.target", httpReq.URL.Path),
+		))
+	return httpReq.WithContext(ctx), span
+}
+
+// endSpan records the response status and error
+func endSpan(span trace.Span, code int, err error) {
+	span.SetAttributes(attribute.Int("http.status_code", code))
+	if err != nil {
+		span.RecordError(err)
+	}
+	if code >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(code))
+	}
+	span.End()
+}
+
+// SpanContext returns the span context of the request being handled,
+// which is invalid unless Config.TracerProvider is set
+func SpanContext(ctx context.Context) trace.SpanContext {
+	return trace.SpanContextFromContext(ctx)
+}
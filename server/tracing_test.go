@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	var inHandler trace.SpanContext
+	h := testHandler(t, Config{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))}, testMS{
+		"get": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+			inHandler = SpanContext(ctx)
+			return "ok", nil
+		}},
+		"fail": testOper{handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, errors.Errorf("downstream failed")
+		}},
+	})
+	do(h, http.MethodGet, "/get", "", "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	do(h, http.MethodGet, "/fail", "")
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	get := spans[0]
+	if get.Name != "get" || get.SpanKind != trace.SpanKindServer || get.Status.Code == codes.Error {
+		t.Fatalf("get span %s kind %v status %v", get.Name, get.SpanKind, get.Status)
+	}
+	if get.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || get.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("traceparent not continued: trace %s parent %s", get.SpanContext.TraceID(), get.Parent.SpanID())
+	}
+	if inHandler.SpanID() != get.SpanContext.SpanID() {
+		t.Fatalf("handler saw span %s, expected %s", inHandler.SpanID(), get.SpanContext.SpanID())
+	}
+	fail := spans[1]
+	if fail.Name != "fail" || fail.Status.Code != codes.Error || len(fail.Events) == 0 || fail.Parent.IsValid() {
+		t.Fatalf("fail span %s status %v events %d parent %v", fail.Name, fail.Status, len(fail.Events), fail.Parent.IsValid())
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	var inHandler trace.SpanContext
+	h := testHandler(t, Config{}, testMS{"get": testOper{handle: func(ctx ms.Context, req interface{}) (interface{}, error) {
+		inHandler = SpanContext(ctx)
+		return "ok", nil
+	}}})
+	do(h, http.MethodGet, "/get", "", "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if inHandler.IsValid() {
+		t.Fatalf("span context %v without a TracerProvider", inHandler)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// newTagValidator returns a validator for `validate:"..."` struct tags
+// that reports fields by their JSON name
+func newTagValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// validateTags checks the `validate` tags of a struct request
+// and returns a ValidationError listing the invalid fields
+func validateTags(v *validator.Validate, req interface{}) error {
+	if t := reflect.TypeOf(req); t.Kind() != reflect.Struct && !(t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct) {
+		return nil
+	}
+	err := v.Struct(req)
+	if err == nil {
+		return nil
+	}
+	fieldErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+	validationErr := ValidationError{Fields: make([]FieldError, len(fieldErrors))}
+	for i, fe := range fieldErrors {
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:] //remove the request type name
+		}
+		message := fmt.Sprintf("%s failed %s", field, fe.Tag())
+		if fe.Param() != "" {
+			message += "=" + fe.Param()
+		}
+		validationErr.Fields[i] = FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message,
+		}
+	}
+	return validationErr
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/errors"
+)
+
+type signupReq struct {
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name" validate:"min=2,max=10"`
+	Age   int    `json:"age" validate:"gte=18"`
+}
+
+// Validate still runs after the tags were checked
+func (r signupReq) Validate() error {
+	if r.Name == "admin" {
+		return errors.Error("reserved name")
+	}
+	return nil
+}
+
+func TestValidateTags(t *testing.T) {
+	svc := testMS{"signup": testOper{reqType: reflect.TypeOf(signupReq{}), handle: echo}}
+	h := testHandler(t, Config{ValidateTags: true}, svc)
+
+	w := do(h, http.MethodPost, "/signup", `{"email":"bob","name":"b","age":12}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	info := errorBody(t, w.Body.Bytes())
+	var fields []FieldError
+	b, _ := json.Marshal(info.Details)
+	if err := json.Unmarshal(b, &fields); err != nil || info.ErrorCode != ErrorCodeValidationFailed {
+		t.Fatalf("got %+v: %v", info, err)
+	}
+	want := []FieldError{
+		{Field: "email", Rule: "email", Message: "email failed email"},
+		{Field: "name", Rule: "min", Param: "2", Message: "name failed min=2"},
+		{Field: "age", Rule: "gte", Param: "18", Message: "age failed gte=18"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("fields %+v", fields)
+	}
+	if w := do(h, http.MethodPost, "/signup", `{"name":"bob","age":20}`); w.Code != http.StatusBadRequest || errorBody(t, w.Body.Bytes()).Details == nil {
+		t.Fatalf("missing required got %d %s", w.Code, w.Body)
+	}
+	if w := do(h, http.MethodPost, "/signup", `{"email":"a@b.com","name":"admin","age":20}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("ms.Validator got %d", w.Code)
+	}
+	if w := do(h, http.MethodPost, "/signup", `{"email":"a@b.com","name":"bob","age":20}`); w.Code != http.StatusOK {
+		t.Fatalf("valid got %d %s", w.Code, w.Body)
+	}
+	if w := do(testHandler(t, Config{}, svc), http.MethodPost, "/signup", `{"email":"bob"}`); w.Code != http.StatusOK {
+		t.Fatalf("tags checked when disabled: %d", w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+)
+
+// VersionHeader selects the version when Config.VersionHeader is set
+const VersionHeader = "Accept-Version"
+
+// service is a micro-service with its route table
+type service struct {
+	ms     ms.MicroService
+	prefix string //path prefix of a service in Config.Services, else ""
+	routes []route
+	//lowercase name -> operation name, only when matching case-insensitive
+	lowerNames map[string]string
+	//operation name -> compiled JSON schemas of operations implementing SchemaOper
+	schemas map[string]operSchemas
+}
+
+func newService(svc ms.MicroService, caseInsensitive bool) (*service, error) {
+	routes, err := routesFor(svc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to build routes")
+	}
+	schemas, err := compileSchemas(svc)
+	if err != nil {
+		return nil, err
+	}
+	s := &service{ms: svc, routes: routes, schemas: schemas}
+	if caseInsensitive {
+		s.lowerNames = map[string]string{}
+		for _, operName := range svc.OperNames() {
+			lower := strings.ToLower(operName)
+			if other, ok := s.lowerNames[lower]; ok {
+				return nil, errors.Errorf("operations %s and %s differ only by case", other, operName)
+			}
+			s.lowerNames[lower] = operName
+		}
+	}
+	return s, nil
+}
+
+// oper returns the operation and its registered name
+// an exact match is preferred over a case-insensitive match
+func (s *service) oper(operName string) (string, ms.Oper, bool) {
+	if oper, ok := s.ms.Oper(operName); ok || s.lowerNames == nil {
+		return operName, oper, ok
+	}
+	if name, ok := s.lowerNames[strings.ToLower(operName)]; ok {
+		oper, ok := s.ms.Oper(name)
+		return name, oper, ok
+	}
+	return operName, nil, false
+}
+
+// serviceFor selects the micro-service for the path prefix or requested version
+// and returns the request URL with the prefix or version removed
+// other requests use the micro-service the server was created with
+func (s *server) serviceFor(httpReq *http.Request) (*service, *url.URL, error) {
+	if svc, u, ok := s.prefixedService(httpReq.URL); ok {
+		return svc, u, nil
+	}
+	if len(s.versions) == 0 {
+		return s.svc, httpReq.URL, nil
+	}
+	u := httpReq.URL
+	var version string
+	if s.config.VersionHeader {
+		version = httpReq.Header.Get(VersionHeader)
+	} else {
+		segment := strings.SplitN(strings.TrimPrefix(u.EscapedPath(), "/"), "/", 2)[0]
+		if _, ok := s.versions[segment]; ok {
+			version = segment
+			stripped := *u
+			stripped.Path = strings.TrimPrefix(u.Path, "/"+segment)
+			stripped.RawPath = strings.TrimPrefix(u.RawPath, "/"+segment)
+			u = &stripped
+		}
+	}
+	if version == "" {
+		if s.config.RequireVersion {
+			return nil, nil, errors.Errorc(http.StatusBadRequest, "missing API version")
+		}
+		return s.svc, u, nil
+	}
+	svc, ok := s.versions[version]
+	if !ok {
+		return nil, nil, errors.Errorc(http.StatusNotFound, fmt.Sprintf("unknown API version %s", version))
+	}
+	return svc, u, nil
+}
+
+// newServices returns the micro-services of Config.Services sorted by longest prefix first
+func newServices(services map[string]ms.MicroService, caseInsensitive bool) ([]*service, error) {
+	list := make([]*service, 0, len(services))
+	for prefix, svcMS := range services {
+		svc, err := newService(svcMS, caseInsensitive)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid service %s", prefix)
+		}
+		svc.prefix = prefix
+		list = append(list, svc)
+	}
+	sort.Slice(list, func(i, j int) bool { return len(list[i].prefix) > len(list[j].prefix) })
+	return list, nil
+}
+
+// prefixedService returns the service with the longest prefix matching whole segments of u
+// and u with the prefix removed
+func (s *server) prefixedService(u *url.URL) (*service, *url.URL, bool) {
+	for _, svc := range s.services {
+		if u.Path != svc.prefix && !strings.HasPrefix(u.Path, svc.prefix+"/") {
+			continue
+		}
+		stripped := *u
+		stripped.Path = strings.TrimPrefix(u.Path, svc.prefix)
+		if u.RawPath != "" {
+			stripped.RawPath = strings.TrimPrefix(u.RawPath, svc.prefix)
+		}
+		return svc, &stripped, true
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-msvc/ms"
+)
+
+func TestVersionPrefix(t *testing.T) {
+	h := testHandler(t, Config{Versions: map[string]ms.MicroService{
+		"v2": testMS{"getUser": testOper{handle: result("two")}},
+	}}, testMS{"getUser": testOper{handle: result("one")}})
+	for target, want := range map[string]string{"/getUser": `"one"`, "/v2/getUser": `"two"`} {
+		if w := do(h, http.MethodGet, target, ""); w.Body.String() != want {
+			t.Fatalf("%s got %d %s", target, w.Code, w.Body)
+		}
+	}
+	if w := do(h, http.MethodGet, "/v3/getUser", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown version got %d", w.Code)
+	}
+}
+
+func TestVersionHeader(t *testing.T) {
+	versions := map[string]ms.MicroService{
+		"1": testMS{"getUser": testOper{handle: result("one")}},
+		"2": testMS{"getUser": testOper{handle: result("two")}},
+	}
+	h := testHandler(t, Config{Versions: versions, VersionHeader: true, RequireVersion: true}, testMS{"getUser": testOper{handle: result("latest")}})
+	for version, want := range map[string]string{"1": `"one"`, "2": `"two"`} {
+		if w := do(h, http.MethodGet, "/getUser", "", VersionHeader, version); w.Body.String() != want {
+			t.Fatalf("version %s got %d %s", version, w.Code, w.Body)
+		}
+	}
+	if w := do(h, http.MethodGet, "/getUser", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing required version got %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/getUser", "", VersionHeader, "9"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown version got %d", w.Code)
+	}
+
+	h = testHandler(t, Config{Versions: versions, VersionHeader: true}, testMS{"getUser": testOper{handle: result("latest")}})
+	if w := do(h, http.MethodGet, "/getUser", ""); w.Body.String() != `"latest"` {
+		t.Fatalf("default version got %s", w.Body)
+	}
+}
+
+func TestServices(t *testing.T) {
+	h := testHandler(t, Config{Services: map[string]ms.MicroService{
+		"/billing":    testMS{"get": testOper{handle: result("billing")}},
+		"/billing/eu": testMS{"get": testOper{handle: result("eu")}},
+		"/users":      testMS{"get": testOper{handle: result("users")}, "list": testOper{handle: result("list")}},
+	}}, testMS{"get": testOper{handle: result("default")}})
+	for path, want := range map[string]string{
+		"/billing/get":    `"billing"`,
+		"/billing/eu/get": `"eu"`,
+		"/users/get":      `"users"`,
+		"/users/list":     `"list"`,
+		"/get":            `"default"`,
+	} {
+		if w := do(h, http.MethodGet, path, ""); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%s got %d %s, expected %s", path, w.Code, w.Body, want)
+		}
+	}
+	for _, path := range []string{"/billingx/get", "/billing/list", "/list", "/other/get"} {
+		if w := do(h, http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s got %d", path, w.Code)
+		}
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	svc := testMS{}
+	for name, c := range map[string]Config{
+		"relative": {Services: map[string]ms.MicroService{"billing": svc}},
+		"trailing": {Services: map[string]ms.MicroService{"/billing/": svc}},
+		"version":  {Services: map[string]ms.MicroService{"/v2/billing": svc}, Versions: map[string]ms.MicroService{"v2": svc}},
+		"handler":  {Services: map[string]ms.MicroService{"/ui": svc}, Handlers: map[string]http.Handler{"/ui/": http.NotFoundHandler()}},
+	} {
+		c.Addr = "localhost"
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s service prefix accepted", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/ms"
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketOper is optionally implemented by an operation to accept WebSocket
+// connections: a request with "Upgrade: websocket" is bound like any other
+// request and handed to ServeWebSocket with the upgraded connection,
+// while other requests are still served by Handle
+type WebSocketOper interface {
+	ServeWebSocket(ctx ms.Context, req interface{}, conn *websocket.Conn) error
+}
+
+// ErrUpgradeRequired can be returned by Handle of a WebSocketOper that
+// only supports WebSocket connections, to respond with 426 Upgrade Required
+var ErrUpgradeRequired = errors.Errorc(http.StatusUpgradeRequired, "websocket upgrade required")
+
+// isWebSocketUpgrade returns true when httpReq asks for a WebSocket connection
+func isWebSocketUpgrade(httpReq *http.Request) bool {
+	if !strings.EqualFold(httpReq.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range strings.Split(httpReq.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(value), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// serveWebSocket upgrades the connection and runs the operation on it
+// errors after the upgrade cannot be written to the client, so they are only logged
+func (s *server) serveWebSocket(httpRes http.ResponseWriter, httpReq *http.Request, ctx ms.Context, req interface{}, wsOper WebSocketOper, operName string, rlog requestLogger) {
+	wsServer := websocket.Server{
+		Handshake: s.checkWebSocketOrigin,
+		Handler: func(conn *websocket.Conn) {
+			conn.SetDeadline(time.Time{}) //server read and write timeouts do not apply to the connection
+			if err := wsOper.ServeWebSocket(ctx, req, conn); err != nil {
+				rlog.Errorf("%s websocket failed: %+v", operName, err)
+			}
+		},
+	}
+	wsServer.ServeHTTP(httpRes, httpReq)
+}
+
+// checkWebSocketOrigin rejects cross-origin connections, unless allowed by Config.CORS
+func (s *server) checkWebSocketOrigin(config *websocket.Config, httpReq *http.Request) error {
+	origin := httpReq.Header.Get("Origin")
+	if origin == "" {
+		return nil //not a browser
+	}
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return errors.Wrapf(err, "invalid origin:%q", origin)
+	}
+	config.Origin = originURL
+	if s.config.CORS != nil {
+		if s.config.CORS.allowedOrigin(origin) == "" {
+			return errors.Errorf("origin:%q not allowed", origin)
+		}
+		return nil
+	}
+	if !strings.EqualFold(originURL.Host, httpReq.Host) {
+		return errors.Errorf("cross-origin websocket from %q not allowed", origin)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-msvc/ms"
+	"golang.org/x/net/websocket"
+)
+
+type feedReq struct {
+	Topic string `query:"topic"`
+}
+
+// echoSocket echoes messages prefixed with the topic of its request
+type echoSocket struct{ testOper }
+
+func (echoSocket) ServeWebSocket(ctx ms.Context, req interface{}, conn *websocket.Conn) error {
+	for {
+		var msg string
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			return nil //closed by the client
+		}
+		if err := websocket.Message.Send(conn, req.(feedReq).Topic+":"+msg); err != nil {
+			return err
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	ts := httptest.NewServer(testHandler(t, Config{}, testMS{
+		"feed": echoSocket{testOper{reqType: reflect.TypeOf(feedReq{}), handle: func(ms.Context, interface{}) (interface{}, error) {
+			return nil, ErrUpgradeRequired
+		}}},
+	}))
+	defer ts.Close()
+
+	conn, err := websocket.Dial("ws"+ts.URL[len("http"):]+"/feed?topic=news", "", ts.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %+v", err)
+	}
+	defer conn.Close()
+	for _, msg := range []string{"one", "two"} {
+		if err := websocket.Message.Send(conn, msg); err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if err := websocket.Message.Receive(conn, &got); err != nil || got != "news:"+msg {
+			t.Fatalf("got %q: %v", got, err)
+		}
+	}
+
+	httpRes, err := http.Get(ts.URL + "/feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpRes.Body.Close()
+	if httpRes.StatusCode != http.StatusUpgradeRequired || httpRes.Header.Get("Upgrade") != "websocket" {
+		t.Fatalf("plain request got %d %v", httpRes.StatusCode, httpRes.Header)
+	}
+}
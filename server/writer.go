@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+
+	"github.com/go-msvc/errors"
+)
+
+// responseWriter records the status code and number of body bytes written
+// so that logs and metrics can report the response actually sent
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	bytesWritten int64
+	capture      *bytes.Buffer //copy of the body when set
+	streamed     bool          //body was flushed in parts
+	trailers     bool          //trailers are declared, which requires a chunked body
+}
+
+// StatusClientClosedRequest is the nginx status logged for a request when the client
+// disconnected before the response was written, it is never sent
+const StatusClientClosedRequest = 499
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+// Status returns the status code sent, 200 if only the body was written
+// or 0 if nothing was sent yet
+func (w *responseWriter) Status() int {
+	return w.statusCode
+}
+
+// clientClosed records StatusClientClosedRequest unless a response was already sent
+func (w *responseWriter) clientClosed() {
+	if w.statusCode == 0 {
+		w.statusCode = StatusClientClosedRequest
+	}
+}
+
+func (w *responseWriter) BytesWritten() int64 {
+	return w.bytesWritten
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	if w.trailers {
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytesWritten += int64(n)
+	if w.capture != nil {
+		w.capture.Write(b[:n])
+	}
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.statusCode == 0 {
+			w.statusCode = http.StatusOK
+		}
+		w.streamed = true
+		flusher.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.Errorf("%T does not support hijacking", w.ResponseWriter)
+	}
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriterStatus(t *testing.T) {
+	w := newResponseWriter(httptest.NewRecorder())
+	if w.Status() != 0 {
+		t.Fatalf("initial status %d", w.Status())
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("hello"))
+	if w.Status() != http.StatusCreated || w.BytesWritten() != 5 {
+		t.Fatalf("got %d, %d bytes", w.Status(), w.BytesWritten())
+	}
+
+	w = newResponseWriter(httptest.NewRecorder())
+	w.Write([]byte("hi"))
+	w.Write([]byte("!"))
+	if w.Status() != http.StatusOK || w.BytesWritten() != 3 {
+		t.Fatalf("write first got %d, %d bytes", w.Status(), w.BytesWritten())
+	}
+
+	var _ http.Flusher = w
+	var _ http.Hijacker = w
+	if _, _, err := w.Hijack(); err == nil {
+		t.Fatal("recorder cannot be hijacked")
+	}
+}